@@ -0,0 +1,135 @@
+package gloat
+
+import (
+	"context"
+	"fmt"
+)
+
+// Mark records a migration from the source as applied, without executing it.
+// Useful for migrations applied by hand, like hot fixes. The recorded
+// migration is returned, or nothing if it was already applied.
+func (c *Gloat) Mark(version int64) (Migrations, error) {
+	return c.MarkContext(context.Background(), version)
+}
+
+// MarkContext records a migration from the source as applied, without
+// executing it. See Mark for the details.
+func (c *Gloat) MarkContext(ctx context.Context, version int64) (marked Migrations, err error) {
+	err = c.withLock(ctx, func() error {
+		appliedMigrations, availableMigrations, err := c.collectBoth(ctx)
+		if err != nil {
+			return err
+		}
+
+		migration := availableMigrations.Find(version)
+		if migration == nil {
+			return fmt.Errorf("cannot mark unknown version %d", version)
+		}
+
+		if appliedMigrations.Has(version) {
+			return nil
+		}
+
+		marked, err = c.mark(ctx, Migrations{migration})
+		return err
+	})
+
+	return
+}
+
+// Unmark removes a migration from the store, without reverting it. The
+// removed migration is returned, or nothing if it was not applied.
+func (c *Gloat) Unmark(version int64) (Migrations, error) {
+	return c.UnmarkContext(context.Background(), version)
+}
+
+// UnmarkContext removes a migration from the store, without reverting it. See
+// Unmark for the details.
+func (c *Gloat) UnmarkContext(ctx context.Context, version int64) (unmarked Migrations, err error) {
+	err = c.withLock(ctx, func() error {
+		appliedMigrations, availableMigrations, err := c.collectBoth(ctx)
+		if err != nil {
+			return err
+		}
+
+		migration := appliedMigrations.Find(version)
+		if migration == nil {
+			return nil
+		}
+
+		// Prefer the source migration, as it knows its name and content.
+		if available := availableMigrations.Find(version); available != nil {
+			migration = available
+		}
+
+		if err := setup(ctx, c.Store); err != nil {
+			return err
+		}
+
+		if err := remove(ctx, c.Store, migration, nil); err != nil {
+			return err
+		}
+
+		unmarked = Migrations{migration}
+		return nil
+	})
+
+	return
+}
+
+// Baseline records every unapplied source migration up to and including a
+// version as applied, without executing them. Used to adopt gloat on
+// databases that already have the schema. The recorded migrations are
+// returned, oldest first.
+func (c *Gloat) Baseline(version int64) (Migrations, error) {
+	return c.BaselineContext(context.Background(), version)
+}
+
+// BaselineContext records every unapplied source migration up to and
+// including a version as applied, without executing them. See Baseline for
+// the details.
+func (c *Gloat) BaselineContext(ctx context.Context, version int64) (marked Migrations, err error) {
+	err = c.withLock(ctx, func() error {
+		appliedMigrations, availableMigrations, err := c.collectBoth(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !availableMigrations.Has(version) {
+			return fmt.Errorf("cannot baseline at unknown version %d", version)
+		}
+
+		unappliedMigrations := appliedMigrations.Except(availableMigrations)
+		unappliedMigrations.Sort()
+
+		var migrations Migrations
+		for _, migration := range unappliedMigrations {
+			if migration.Version <= version {
+				migrations = append(migrations, migration)
+			}
+		}
+
+		marked, err = c.mark(ctx, migrations)
+		return err
+	})
+
+	return
+}
+
+func (c *Gloat) mark(ctx context.Context, migrations Migrations) (marked Migrations, err error) {
+	if err = setup(ctx, c.Store); err != nil {
+		return
+	}
+
+	for _, migration := range migrations {
+		migration.Checksum = c.Checksum.Sum(migration.UpSQL)
+
+		if err = insert(ctx, c.Store, migration, nil); err != nil {
+			return
+		}
+
+		marked = append(marked, migration)
+	}
+
+	return
+}
@@ -0,0 +1,74 @@
+package gloat
+
+import (
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func TestMarkAndUnmark(t *testing.T) {
+	dbStore, err := databaseStoreFactory(dbDriver, db)
+	assert.Nil(t, err)
+
+	gl.Store = dbStore
+	defer func() { gl.Store = new(testingStore) }()
+
+	cleanState(func() {
+		marked, err := gl.Mark(20170511172647)
+		assert.Nil(t, err)
+		assert.Len(t, 1, marked)
+		assert.Equal(t, "irreversible_migration_brah", marked[0].Name())
+
+		marked, err = gl.Mark(20170511172647)
+		assert.Nil(t, err)
+		assert.Len(t, 0, marked)
+
+		_, err = gl.Mark(42)
+		assert.Error(t, err)
+
+		migrations, err := dbStore.Collect()
+		assert.Nil(t, err)
+		assert.Len(t, 1, migrations)
+		assert.NotEqual(t, "", migrations[0].Checksum)
+
+		unmarked, err := gl.Unmark(20170511172647)
+		assert.Nil(t, err)
+		assert.Len(t, 1, unmarked)
+
+		unmarked, err = gl.Unmark(20170511172647)
+		assert.Nil(t, err)
+		assert.Len(t, 0, unmarked)
+
+		migrations, err = dbStore.Collect()
+		assert.Nil(t, err)
+		assert.Len(t, 0, migrations)
+	})
+}
+
+func TestBaseline(t *testing.T) {
+	dbStore, err := databaseStoreFactory(dbDriver, db)
+	assert.Nil(t, err)
+
+	gl.Store = dbStore
+	defer func() { gl.Store = new(testingStore) }()
+
+	cleanState(func() {
+		_, err := gl.Mark(20170329154959)
+		assert.Nil(t, err)
+
+		marked, err := gl.Baseline(20180905150724)
+		assert.Nil(t, err)
+
+		assert.Len(t, 2, marked)
+		assert.Equal(t, 20170511172647, marked[0].Version)
+		assert.Equal(t, 20180905150724, marked[1].Version)
+
+		unapplied, err := gl.Unapplied()
+		assert.Nil(t, err)
+		assert.Len(t, 1, unapplied)
+		assert.Equal(t, 20180920181906, unapplied[0].Version)
+
+		_, err = gl.Baseline(42)
+		assert.Error(t, err)
+	})
+}
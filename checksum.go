@@ -0,0 +1,122 @@
+package gloat
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ChecksumOptions configure how the UpSQL content is normalized before it is
+// hashed. Normalization keeps checkouts with different line endings or editor
+// settings from being reported as drifted.
+//
+// The same options have to be used when applying and verifying migrations, as
+// the recorded checksums are compared byte for byte.
+type ChecksumOptions struct {
+	// NormalizeLineEndings converts CRLF and CR line endings to LF.
+	NormalizeLineEndings bool
+
+	// TrimTrailingWhitespace removes the whitespace at the end of every line
+	// and the blank lines at the end of the content.
+	TrimTrailingWhitespace bool
+}
+
+// Sum returns the hex encoded SHA-256 hash of the normalized data.
+func (o ChecksumOptions) Sum(data []byte) string {
+	if o.NormalizeLineEndings {
+		data = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+		data = bytes.Replace(data, []byte("\r"), []byte("\n"), -1)
+	}
+
+	if o.TrimTrailingWhitespace {
+		lines := bytes.Split(data, []byte("\n"))
+		for i, line := range lines {
+			lines[i] = bytes.TrimRight(line, " \t")
+		}
+
+		data = bytes.TrimRight(bytes.Join(lines, []byte("\n")), "\n")
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func checksum(data []byte) string {
+	return ChecksumOptions{}.Sum(data)
+}
+
+// Drift is an applied migration whose source no longer matches the checksum
+// recorded at the time it was applied.
+type Drift struct {
+	Version  int64
+	Path     string
+	Recorded string
+	Actual   string
+}
+
+// DriftError is the error returned when applied migrations have been edited
+// after they were applied.
+type DriftError struct {
+	Drifts []Drift
+}
+
+// Error implements the error interface.
+func (err DriftError) Error() string {
+	versions := make([]string, len(err.Drifts))
+	for i, drift := range err.Drifts {
+		versions[i] = fmt.Sprint(drift.Version)
+	}
+
+	return fmt.Sprintf("applied migrations have changed: %s", strings.Join(versions, ", "))
+}
+
+// Verify compares the checksums recorded in the Store against the migrations
+// in the Source and returns a DriftError listing every migration that changed
+// after it was applied.
+//
+// Migrations applied without a checksum, e.g. by an older gloat version, and
+// migrations missing from the source are not verified.
+func (c *Gloat) Verify() error {
+	return c.VerifyContext(context.Background())
+}
+
+// VerifyContext compares the recorded checksums against the migrations in the
+// Source. See Verify for the details.
+func (c *Gloat) VerifyContext(ctx context.Context) error {
+	appliedMigrations, availableMigrations, err := c.collectBoth(ctx)
+	if err != nil {
+		return err
+	}
+
+	appliedMigrations.Sort()
+
+	var drifts []Drift
+	for _, applied := range appliedMigrations {
+		if applied.Checksum == "" {
+			continue
+		}
+
+		migration := availableMigrations.Find(applied.Version)
+		if migration == nil {
+			continue
+		}
+
+		if actual := c.Checksum.Sum(migration.UpSQL); actual != applied.Checksum {
+			drifts = append(drifts, Drift{
+				Version:  migration.Version,
+				Path:     migration.Path,
+				Recorded: applied.Checksum,
+				Actual:   actual,
+			})
+		}
+	}
+
+	if len(drifts) != 0 {
+		return DriftError{Drifts: drifts}
+	}
+
+	return nil
+}
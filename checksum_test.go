@@ -0,0 +1,71 @@
+package gloat
+
+import (
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func TestChecksumOptionsSum(t *testing.T) {
+	unix := []byte("CREATE TABLE users ();\nDROP TABLE users;\n")
+	windows := []byte("CREATE TABLE users ();  \r\nDROP TABLE users;\r\n\r\n")
+
+	assert.NotEqual(t, ChecksumOptions{}.Sum(unix), ChecksumOptions{}.Sum(windows))
+
+	normalize := ChecksumOptions{NormalizeLineEndings: true, TrimTrailingWhitespace: true}
+	assert.Equal(t, normalize.Sum(unix), normalize.Sum(windows))
+
+	onlyLineEndings := ChecksumOptions{NormalizeLineEndings: true}
+	assert.NotEqual(t, onlyLineEndings.Sum(unix), onlyLineEndings.Sum(windows))
+}
+
+func TestVerify(t *testing.T) {
+	migrations, err := gl.Source.Collect()
+	assert.Nil(t, err)
+
+	gl.Store = &testingStore{
+		applied: Migrations{
+			&Migration{Version: 20170329154959, Checksum: checksum(migrations[0].UpSQL)},
+			&Migration{Version: 20170511172647, Checksum: checksum([]byte("edited"))},
+			&Migration{Version: 20180905150724},
+			&Migration{Version: 20190101000000, Checksum: checksum([]byte("missing"))},
+		},
+	}
+
+	err = gl.Verify()
+	assert.Equal(t, DriftError{
+		Drifts: []Drift{
+			{
+				Version:  20170511172647,
+				Path:     migrations[1].Path,
+				Recorded: checksum([]byte("edited")),
+				Actual:   checksum(migrations[1].UpSQL),
+			},
+		},
+	}, err)
+}
+
+func TestVerify_Clean(t *testing.T) {
+	migrations, err := gl.Source.Collect()
+	assert.Nil(t, err)
+
+	gl.Store = &testingStore{
+		applied: Migrations{
+			&Migration{Version: 20170329154959, Checksum: checksum(migrations[0].UpSQL)},
+		},
+	}
+
+	assert.Nil(t, gl.Verify())
+}
+
+func TestApply_RecordsChecksum(t *testing.T) {
+	migration := &Migration{UpSQL: []byte("SELECT 1;\r\n")}
+
+	gl.Store = &testingStore{}
+	gl.Checksum = ChecksumOptions{NormalizeLineEndings: true}
+	defer func() { gl.Checksum = ChecksumOptions{} }()
+
+	assert.Nil(t, gl.Apply(migration))
+
+	assert.Equal(t, checksum([]byte("SELECT 1;\n")), migration.Checksum)
+}
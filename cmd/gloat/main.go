@@ -1,16 +1,24 @@
 package main
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/gsamokovarov/gloat"
+	"github.com/gsamokovarov/gloat/lint"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
@@ -23,21 +31,105 @@ Gloat is a Go SQL migration utility.
 
 Commands:
   new           Create a new migration folder
+                -kind sql, single or go picks the layout of the migration
+                -single creates a single .sql file with up and down sections
+                -no-down creates an irreversible migration
+                -no-transaction runs the migration outside of a transaction
+                -versioning timestamp or sequential picks the version format,
+                sequential numbers them 0001, 0002 and so on after the
+                highest version in the migrations folder (default timestamp)
+                -templates DIR renders the migration from the up.sql.tmpl,
+                down.sql.tmpl, single.sql.tmpl or go.tmpl templates in a
+                folder (default $GLOAT_TEMPLATES)
   up            Apply new migrations
+                -n N applies only the next N migrations
   down          Revert the last applied migration
+                -n N reverts the last N migrations
+  migrate       Apply or revert migrations to reach a version
+                -to VERSION is the version to reach, 0 reverts everything
+  status        Show the applied, pending, missing and dirty migrations
+                -exit-code exits with 1 if anything is pending, missing or
+                dirty
+  force         Resolve a dirty migration after repairing the database by
+                hand, recording it as applied
+                -down removes the migration from the store instead
+  mark          Record a migration as applied, without running it
+  unmark        Remove a migration from the store, without reverting it
+  baseline      Record every migration up to a version as applied, without
+                running them
+  squash        Combine the migrations up to a version into one migration
+                -to VERSION is the last migration to squash
+                -archive DIR moves the squashed migrations to a folder
+                -delete deletes the squashed migrations instead
+                Prints the SQL recording the squashed migration in the
+                databases that applied the originals
+  verify        Check that applied migrations were not edited afterwards
+  lint          Check the migrations for dangerous SQL, like dropped tables
+                or indexes blocking writes, and exit with 1 on problems
+                -disable RULE,... turns rules off
+                -large-tables TABLE,... checks the PostgreSQL indexes only on
+                these tables
+                The -url only picks the dialect, the database is not queried
+                Migrations can turn rules off with "lint_ignore" in their
+                options
+  script        Print an SQL script migrating a database by hand
+                -from VERSION is the version of the database, 0 if empty
+                -to VERSION is the version to reach (default latest)
+                The -url only picks the dialect, the database is not queried
+  schema dump   Write the structure of the database and the applied
+                migrations to a file that can be reviewed in diffs
+                -file PATH is the file to write (default schema.sql next to
+                the migrations folder)
+                -check fails if the file is out of date, instead of writing
+  schema load   Create a fresh database from a schema file and record its
+                migrations as applied
+                -file PATH is the file to load (default schema.sql next to
+                the migrations folder)
 
 Options:
   -src          The folder with migrations
                 (default $DATABASE_SRC or db/migrations)
   -url          The database connection URL
                 (default $DATABASE_URL)
+  -table        The table the applied migrations are recorded in
+                (default schema_migrations)
+  -schema       The schema of the migrations table, PostgreSQL only
+  -dry-run      Print the migrations up, down and migrate would run, along
+                with their bookkeeping SQL, without executing them
+  -out-of-order What to do with pending migrations older than the latest
+                applied one: error, warn or allow (default error)
+  -lock-timeout How long to wait for other running migrations, e.g. 30s
+                (default 0, waits until the lock is free)
+  -normalize-line-endings
+                Convert CRLF line endings to LF before checksumming
+  -trim-trailing-whitespace
+                Trim trailing whitespace before checksumming
+  -skip-unknown Skip the hidden entries, like .git, the entries without a
+                version and the files that are not migrations in the
+                migrations folder, instead of failing
+  -var KEY=VALUE
+                A variable for the migrations with "template": true in their
+                options, used as {{.KEY}}, can be repeated
+  -vars-file    A JSON file with variables, like {"schema": "tenant"}, that
+                -var overrides
   -help         Show this message
 `
 
 type arguments struct {
-	url  string
-	src  string
-	rest []string
+	url    string
+	src    string
+	table  string
+	schema string
+	rest   []string
+
+	dryRun                 bool
+	outOfOrder             string
+	lockTimeout            time.Duration
+	normalizeLineEndings   bool
+	trimTrailingWhitespace bool
+	skipUnknown            bool
+	vars                   varsFlag
+	varsFile               string
 }
 
 func main() {
@@ -54,6 +146,28 @@ func main() {
 		err = upCmd(args)
 	case "down":
 		err = downCmd(args)
+	case "migrate":
+		err = migrateCmd(args)
+	case "status":
+		err = statusCmd(args)
+	case "force":
+		err = forceCmd(args)
+	case "mark":
+		err = markCmd(args)
+	case "unmark":
+		err = unmarkCmd(args)
+	case "baseline":
+		err = baselineCmd(args)
+	case "squash":
+		err = squashCmd(args)
+	case "verify":
+		err = verifyCmd(args)
+	case "lint":
+		err = lintCmd(args)
+	case "script":
+		err = scriptCmd(args)
+	case "schema":
+		err = schemaCmd(args)
 	case "new":
 		err = newCmd(args)
 	default:
@@ -63,98 +177,731 @@ func main() {
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %+v\n", err)
+
+		var migrationErr gloat.MigrationError
+		if errors.As(err, &migrationErr) {
+			fmt.Fprintf(os.Stderr, "\n%s", migrationErr.Frame())
+		}
+
 		os.Exit(2)
 	}
 }
 
 func upCmd(args arguments) error {
+	var n int
+
+	flags := flag.NewFlagSet("up", flag.ContinueOnError)
+	flags.IntVar(&n, "n", 0, "number of migrations to apply")
+	if err := flags.Parse(args.rest[1:]); err != nil {
+		return err
+	}
+
+	if n < 0 {
+		return errors.New("up requires a non-negative -n")
+	}
+
+	gl, err := setupGloat(args)
+	if err != nil {
+		return err
+	}
+
+	var plan gloat.Plan
+	if n == 0 {
+		plan, err = gl.MigrateTo(gloat.Latest)
+	} else {
+		plan, err = gl.Steps(n)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(plan) == 0 {
+		fmt.Printf("No migrations to apply\n")
+	}
+
+	return nil
+}
+
+func downCmd(args arguments) error {
+	var n int
+
+	flags := flag.NewFlagSet("down", flag.ContinueOnError)
+	flags.IntVar(&n, "n", 1, "number of migrations to revert")
+	if err := flags.Parse(args.rest[1:]); err != nil {
+		return err
+	}
+
+	if n < 1 {
+		return errors.New("down requires a positive -n")
+	}
+
+	gl, err := setupGloat(args)
+	if err != nil {
+		return err
+	}
+
+	if _, err := gl.Steps(-n); err != nil {
+		if errors.Is(err, gloat.ErrNoCurrentMigration) {
+			fmt.Printf("No migrations to revert\n")
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func migrateCmd(args arguments) error {
+	var to int64
+
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.Int64Var(&to, "to", -1, "version to migrate to")
+	if err := flags.Parse(args.rest[1:]); err != nil {
+		return err
+	}
+
+	if to < 0 {
+		return errors.New("migrate requires a version given with -to")
+	}
+
+	gl, err := setupGloat(args)
+	if err != nil {
+		return err
+	}
+
+	plan, err := gl.MigrateTo(to)
+	if err != nil {
+		return err
+	}
+
+	if len(plan) == 0 {
+		fmt.Printf("Already at version %d\n", to)
+	}
+
+	return nil
+}
+
+func statusCmd(args arguments) error {
+	var exitCode bool
+
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	flags.BoolVar(&exitCode, "exit-code", false, "exit with 1 if anything is pending, missing or dirty")
+	if err := flags.Parse(args.rest[1:]); err != nil {
+		return err
+	}
+
+	gl, err := setupGloat(args)
+	if err != nil {
+		return err
+	}
+
+	status, err := gl.Status()
+	if err != nil {
+		return err
+	}
+
+	if len(status.Migrations) == 0 {
+		fmt.Printf("No migrations\n")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "VERSION\tNAME\tSTATE\tAPPLIED AT\n")
+	for _, migration := range status.Migrations {
+		appliedAt := ""
+		if !migration.AppliedAt.IsZero() {
+			appliedAt = migration.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", migration.Version, migration.Name, migration.State, appliedAt)
+	}
+	w.Flush()
+
+	if exitCode && !status.Clean() {
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+func forceCmd(args arguments) error {
+	var forceDown bool
+
+	flags := flag.NewFlagSet("force", flag.ContinueOnError)
+	flags.BoolVar(&forceDown, "down", false, "remove the migration from the store")
+	if err := flags.Parse(args.rest[1:]); err != nil {
+		return err
+	}
+
+	version, err := versionArgument("force", flags.Args())
+	if err != nil {
+		return err
+	}
+
+	gl, err := setupGloat(args)
+	if err != nil {
+		return err
+	}
+
+	direction := gloat.Up
+	if forceDown {
+		direction = gloat.Down
+	}
+
+	if err := gl.Force(version, direction); err != nil {
+		return err
+	}
+
+	fmt.Printf("Forced %d %s\n", version, direction)
+
+	return nil
+}
+
+func markCmd(args arguments) error {
+	version, err := versionArgument("mark", args.rest[1:])
+	if err != nil {
+		return err
+	}
+
+	gl, err := setupGloat(args)
+	if err != nil {
+		return err
+	}
+
+	migrations, err := gl.Mark(version)
+	if err != nil {
+		return err
+	}
+
+	printChanges("Marked", migrations)
+
+	return nil
+}
+
+func unmarkCmd(args arguments) error {
+	version, err := versionArgument("unmark", args.rest[1:])
+	if err != nil {
+		return err
+	}
+
+	gl, err := setupGloat(args)
+	if err != nil {
+		return err
+	}
+
+	migrations, err := gl.Unmark(version)
+	if err != nil {
+		return err
+	}
+
+	printChanges("Unmarked", migrations)
+
+	return nil
+}
+
+func baselineCmd(args arguments) error {
+	version, err := versionArgument("baseline", args.rest[1:])
+	if err != nil {
+		return err
+	}
+
 	gl, err := setupGloat(args)
 	if err != nil {
 		return err
 	}
 
-	migrations, err := gl.Unapplied()
+	migrations, err := gl.Baseline(version)
 	if err != nil {
 		return err
 	}
 
-	appliedMigrations := map[int64]bool{}
+	printChanges("Marked", migrations)
+
+	return nil
+}
+
+func versionArgument(cmdName string, rest []string) (int64, error) {
+	if len(rest) == 0 {
+		return 0, fmt.Errorf("%s requires a migration version given as an argument", cmdName)
+	}
+
+	version, err := strconv.ParseInt(rest[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid version %s", rest[0])
+	}
+
+	return version, nil
+}
+
+func printChanges(change string, migrations gloat.Migrations) {
+	if len(migrations) == 0 {
+		fmt.Printf("Nothing changed\n")
+	}
 
 	for _, migration := range migrations {
-		fmt.Printf("Applying: %d...\n", migration.Version)
+		fmt.Printf("%s: %d %s\n", change, migration.Version, migration.Name())
+	}
+}
 
-		if err := gl.Apply(migration); err != nil {
+func verifyCmd(args arguments) error {
+	gl, err := setupGloat(args)
+	if err != nil {
+		return err
+	}
+
+	err = gl.Verify()
+	if driftErr, ok := err.(gloat.DriftError); ok {
+		for _, drift := range driftErr.Drifts {
+			fmt.Printf("Drifted: %s (recorded %s, found %s)\n", drift.Path, drift.Recorded, drift.Actual)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("No drifted migrations\n")
+
+	return nil
+}
+
+func lintCmd(args arguments) error {
+	var disable, largeTables string
+
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.StringVar(&disable, "disable", "", "comma separated rules to turn off")
+	flags.StringVar(&largeTables, "large-tables", "", "comma separated tables to check the indexes of")
+	if err := flags.Parse(args.rest[1:]); err != nil {
+		return err
+	}
+
+	var dialect gloat.Dialect
+	if args.url != "" {
+		driver, _, err := openDatabase(args.url)
+		if err != nil {
 			return err
 		}
 
-		appliedMigrations[migration.Version] = true
+		dialect = gloat.Dialect(driver)
 	}
 
-	if len(appliedMigrations) == 0 {
-		fmt.Printf("No migrations to apply\n")
+	known := make(map[string]bool)
+	for _, rule := range lint.DefaultRules() {
+		known[rule.Name] = true
+	}
+
+	disabled := make(map[string]bool)
+	for _, name := range splitList(disable) {
+		if !known[name] {
+			return fmt.Errorf("-disable has an unknown rule %s", name)
+		}
+
+		disabled[name] = true
 	}
 
+	var rules []lint.Rule
+	for _, rule := range lint.DefaultRules() {
+		if rule.Name == "create-index-non-concurrently" {
+			rule = lint.CreateIndexNonConcurrently(splitList(largeTables)...)
+		}
+
+		if !disabled[rule.Name] {
+			rules = append(rules, rule)
+		}
+	}
+
+	migrations, err := fileSystemSource(args).Collect()
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		for _, name := range migration.Options.LintIgnore {
+			if !known[name] {
+				return fmt.Errorf("%s: lint_ignore has an unknown rule %s", migration.Path, name)
+			}
+		}
+	}
+
+	problems, err := (&lint.Linter{Dialect: dialect, Rules: rules}).Lint(migrations)
+	if err != nil {
+		return err
+	}
+
+	if len(problems) != 0 {
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+
+		os.Exit(1)
+	}
+
+	fmt.Printf("No problems found\n")
+
 	return nil
 }
 
-func downCmd(args arguments) error {
+func splitList(list string) (items []string) {
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return
+}
+
+func scriptCmd(args arguments) error {
+	var from, to int64
+
+	flags := flag.NewFlagSet("script", flag.ContinueOnError)
+	flags.Int64Var(&from, "from", 0, "version of the database")
+	flags.Int64Var(&to, "to", gloat.Latest, "version to migrate to")
+	if err := flags.Parse(args.rest[1:]); err != nil {
+		return err
+	}
+
+	gl, err := setupGloat(args)
+	if err != nil {
+		return err
+	}
+
+	return gl.Script(os.Stdout, from, to)
+}
+
+func squashCmd(args arguments) error {
+	var (
+		to      int64
+		archive string
+		remove  bool
+	)
+
+	flags := flag.NewFlagSet("squash", flag.ContinueOnError)
+	flags.Int64Var(&to, "to", 0, "last migration to squash")
+	flags.StringVar(&archive, "archive", "", "folder to move the squashed migrations to")
+	flags.BoolVar(&remove, "delete", false, "delete the squashed migrations")
+	if err := flags.Parse(args.rest[1:]); err != nil {
+		return err
+	}
+
+	if to == 0 {
+		return errors.New("squash requires a version given with -to")
+	}
+
+	if (archive == "") == !remove {
+		return errors.New("squash requires either -archive or -delete")
+	}
+
 	gl, err := setupGloat(args)
 	if err != nil {
 		return err
 	}
 
-	migration, err := gl.Current()
+	store, ok := gl.Store.(gloat.StatementStore)
+	if !ok {
+		return errors.New("the store cannot render SQL statements")
+	}
+
+	migrations, err := gl.Source.Collect()
 	if err != nil {
 		return err
 	}
 
-	if migration == nil {
-		fmt.Printf("No migrations to apply\n")
+	squashed, originals, err := migrations.Squash(to)
+	if err != nil {
+		return err
+	}
+	squashed.Checksum = gl.Checksum.Sum(squashed.UpSQL)
+
+	// Move the originals out of the way before writing the squashed migration,
+	// as it takes the version of the last one. Deleted migrations are staged
+	// in a temporary folder next to the migrations, so everything can be put
+	// back if writing the squashed migration fails.
+	staging := archive
+	if remove {
+		if staging, err = ioutil.TempDir(filepath.Dir(args.src), ".gloat-squash"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(staging)
+	} else if err := os.MkdirAll(staging, 0755); err != nil {
+		return err
+	}
+
+	moved, err := moveMigrations(originals, staging)
+	if err != nil {
+		return restoreMigrations(moved, err)
+	}
+
+	squashedPath := filepath.Join(args.src, squashed.Path)
+	if err := writeMigrationDirectory(squashedPath, squashed); err != nil {
+		os.RemoveAll(squashedPath)
+		return restoreMigrations(moved, err)
+	}
+
+	// Keep the standard output for the SQL, so it can be redirected to a file.
+	fmt.Fprintf(os.Stderr, "Squashed %d migrations into %s\n", len(originals), squashedPath)
+	fmt.Fprintf(os.Stderr, "Run the following SQL on the databases that applied them:\n\n")
+
+	return gloat.WriteSquashBookkeeping(os.Stdout, squashed, originals, store)
+}
+
+// moveMigrations moves the folders of migrations into another folder. The
+// original and the new paths of the moved ones are returned, even on errors.
+func moveMigrations(migrations gloat.Migrations, dir string) (map[string]string, error) {
+	moved := map[string]string{}
+
+	for _, migration := range migrations {
+		path := filepath.Join(dir, filepath.Base(migration.Path))
+		if err := os.Rename(migration.Path, path); err != nil {
+			return moved, err
+		}
+
+		moved[migration.Path] = path
+	}
+
+	return moved, nil
+}
+
+// restoreMigrations moves the migrations from moveMigrations back and returns
+// the error that made them move back.
+func restoreMigrations(moved map[string]string, err error) error {
+	for original, path := range moved {
+		if restoreErr := os.Rename(path, original); restoreErr != nil {
+			return fmt.Errorf("%v, and %s could not be moved back to %s: %v", err, path, original, restoreErr)
+		}
+	}
+
+	return err
+}
+
+func schemaCmd(args arguments) error {
+	var subcmdName string
+	if len(args.rest) > 1 {
+		subcmdName = args.rest[1]
+	}
+
+	switch subcmdName {
+	case "dump":
+		return schemaDumpCmd(args)
+	case "load":
+		return schemaLoadCmd(args)
+	}
+
+	return errors.New("schema requires a subcommand: dump or load")
+}
+
+func schemaDumpCmd(args arguments) error {
+	var (
+		file  string
+		check bool
+	)
+
+	flags := flag.NewFlagSet("schema dump", flag.ContinueOnError)
+	flags.StringVar(&file, "file", defaultSchemaFile(args), "file to write the schema to")
+	flags.BoolVar(&check, "check", false, "fail if the file is out of date")
+	if err := flags.Parse(args.rest[2:]); err != nil {
+		return err
+	}
+
+	driver, db, err := openDatabase(args.url)
+	if err != nil {
+		return err
+	}
+
+	gl, err := newGloat(args, driver, db)
+	if err != nil {
+		return err
+	}
+
+	dumper, err := databaseSchemaDumperFactory(driver, db)
+	if err != nil {
+		return err
+	}
+
+	var schema bytes.Buffer
+	if err := gl.DumpSchema(&schema, dumper); err != nil {
+		return err
+	}
+
+	if check {
+		committed, err := ioutil.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if !bytes.Equal(committed, schema.Bytes()) {
+			return fmt.Errorf("%s is out of date, regenerate it with gloat schema dump", file)
+		}
+
+		fmt.Printf("%s is up to date\n", file)
+
 		return nil
 	}
 
-	fmt.Printf("Reverting: %d...\n", migration.Version)
+	if err := ioutil.WriteFile(file, schema.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Dumped %s\n", file)
+
+	return nil
+}
 
-	if err := gl.Revert(migration); err != nil {
+func schemaLoadCmd(args arguments) error {
+	var file string
+
+	flags := flag.NewFlagSet("schema load", flag.ContinueOnError)
+	flags.StringVar(&file, "file", defaultSchemaFile(args), "file to load the schema from")
+	if err := flags.Parse(args.rest[2:]); err != nil {
+		return err
+	}
+
+	if args.dryRun {
+		return errors.New("schema load does not support -dry-run")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
 		return err
 	}
+	defer f.Close()
+
+	driver, db, err := openDatabase(args.url)
+	if err != nil {
+		return err
+	}
+
+	gl, err := newGloat(args, driver, db)
+	if err != nil {
+		return err
+	}
+
+	loaded, err := gl.LoadSchema(f, db, gloat.Dialect(driver))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Loaded %s\n", file)
+	printChanges("Recorded", loaded)
 
 	return nil
 }
 
+// defaultSchemaFile is the schema.sql file next to the migrations folder.
+func defaultSchemaFile(args arguments) string {
+	return filepath.Join(filepath.Dir(filepath.Clean(args.src)), "schema.sql")
+}
+
+// writeMigrationDirectory writes a migration in the up.sql, down.sql and
+// options.json layout. The options are written only if they differ from the
+// defaults.
+func writeMigrationDirectory(path string, migration *gloat.Migration) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(path, "up.sql"), migration.UpSQL, 0644); err != nil {
+		return err
+	}
+
+	if migration.DownSQL != nil {
+		if err := ioutil.WriteFile(filepath.Join(path, "down.sql"), migration.DownSQL, 0644); err != nil {
+			return err
+		}
+	}
+
+	if migration.Options.IsDefault() {
+		return nil
+	}
+
+	options, err := json.MarshalIndent(migration.Options, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(path, "options.json"), append(options, '\n'), 0644)
+}
+
 func newCmd(args arguments) error {
+	var (
+		single        bool
+		kind          string
+		templatesDir  string
+		noDown        bool
+		noTransaction bool
+		versioning    string
+	)
+
+	flags := flag.NewFlagSet("new", flag.ContinueOnError)
+	flags.BoolVar(&single, "single", false, "create a single-file migration")
+	flags.StringVar(&kind, "kind", string(gloat.DirectoryMigration), "the layout of the migration: sql, single or go")
+	flags.StringVar(&templatesDir, "templates", os.Getenv("GLOAT_TEMPLATES"), "folder with migration templates")
+	flags.BoolVar(&noDown, "no-down", false, "create an irreversible migration")
+	flags.BoolVar(&noTransaction, "no-transaction", false, "run the migration outside of a transaction")
+	flags.StringVar(&versioning, "versioning", "timestamp", "how the version is generated: timestamp or sequential")
+	if err := flags.Parse(args.rest[1:]); err != nil {
+		return err
+	}
+
 	if _, err := os.Stat(args.src); os.IsNotExist(err) {
 		return err
 	}
 
-	if len(args.rest) < 2 {
+	if flags.NArg() == 0 {
 		return errors.New("new requires a migration name given as an argument")
 	}
 
-	migration := gloat.GenerateMigration(strings.Join(args.rest[1:], "_"))
-	migrationDirectoryPath := filepath.Join(args.src, migration.Path)
+	if single {
+		kind = string(gloat.SingleFileMigration)
+	}
+
+	scaffold := &gloat.Scaffold{Kind: gloat.MigrationKind(kind), NoDown: noDown}
+
+	if templatesDir != "" {
+		templates, err := gloat.ParseMigrationTemplates(templatesDir)
+		if err != nil {
+			return err
+		}
 
-	if err := os.MkdirAll(migrationDirectoryPath, 0755); err != nil {
+		scaffold.Templates = templates
+	}
+
+	generator, err := versionGenerator(versioning, args)
+	if err != nil {
 		return err
 	}
 
-	f, err := os.Create(filepath.Join(migrationDirectoryPath, "up.sql"))
+	migration, err := gloat.GenerateMigrationWithVersion(generator, strings.Join(flags.Args(), "_"))
 	if err != nil {
 		return err
 	}
-	f.Close()
+	migration.Options.Transaction = !noTransaction
 
-	f, err = os.Create(filepath.Join(migrationDirectoryPath, "down.sql"))
+	path, err := scaffold.Write(args.src, migration)
 	if err != nil {
 		return err
 	}
-	f.Close()
 
-	fmt.Printf("Created %s\n", migrationDirectoryPath)
+	fmt.Printf("Created %s\n", path)
 
 	return nil
 }
 
+func versionGenerator(versioning string, args arguments) (gloat.VersionGenerator, error) {
+	switch versioning {
+	case "timestamp":
+		return gloat.NewTimestampVersionGenerator(), nil
+	case "sequential":
+		return gloat.NewSequentialVersionGenerator(fileSystemSource(args)), nil
+	}
+
+	return nil, errors.New("-versioning must be timestamp or sequential")
+}
+
 func parseArguments() arguments {
 	var args arguments
 
@@ -169,6 +916,16 @@ func parseArguments() arguments {
 
 	flag.StringVar(&args.url, "url", urlDefault, urlUsage)
 	flag.StringVar(&args.src, "src", srcDefault, srcUsage)
+	flag.StringVar(&args.table, "table", "schema_migrations", "the table the applied migrations are recorded in")
+	flag.StringVar(&args.schema, "schema", "", "the schema of the migrations table")
+	flag.BoolVar(&args.dryRun, "dry-run", false, "print the migrations instead of executing them")
+	flag.StringVar(&args.outOfOrder, "out-of-order", "error", "what to do with pending migrations older than the latest applied one")
+	flag.DurationVar(&args.lockTimeout, "lock-timeout", 0, "how long to wait for other running migrations")
+	flag.BoolVar(&args.normalizeLineEndings, "normalize-line-endings", false, "convert CRLF line endings to LF before checksumming")
+	flag.BoolVar(&args.trimTrailingWhitespace, "trim-trailing-whitespace", false, "trim trailing whitespace before checksumming")
+	flag.BoolVar(&args.skipUnknown, "skip-unknown", false, "skip hidden entries, entries without a version and non-migration files in the migrations folder")
+	flag.Var(&args.vars, "var", "a key=value variable for templated migrations, can be repeated")
+	flag.StringVar(&args.varsFile, "vars-file", "", "a JSON file with variables for templated migrations")
 
 	flag.Usage = func() { fmt.Fprintf(os.Stderr, usage) }
 
@@ -180,36 +937,214 @@ func parseArguments() arguments {
 }
 
 func setupGloat(args arguments) (*gloat.Gloat, error) {
-	u, err := url.Parse(args.url)
+	driver, db, err := openDatabase(args.url)
+	if err != nil {
+		return nil, err
+	}
+
+	return newGloat(args, driver, db)
+}
+
+func newGloat(args arguments, driver string, db *sql.DB) (*gloat.Gloat, error) {
+	store, err := databaseStoreFactory(driver, db, args)
+	if err != nil {
+		return nil, err
+	}
+
+	locker, err := databaseLockerFactory(driver, db, args)
 	if err != nil {
 		return nil, err
 	}
 
-	db, err := sql.Open(u.Scheme, args.url)
+	outOfOrder, err := outOfOrderPolicy(args.outOfOrder)
 	if err != nil {
 		return nil, err
 	}
 
-	store, err := databaseStoreFactory(u.Scheme, db)
+	vars, err := templateVars(args)
 	if err != nil {
 		return nil, err
 	}
 
-	return &gloat.Gloat{
-		Store:    store,
-		Source:   gloat.NewFileSystemSource(args.src),
-		Executor: gloat.NewSQLExecutor(db),
-	}, nil
+	gl := &gloat.Gloat{
+		Store:       store,
+		Source:      fileSystemSource(args),
+		Executor:    gloat.NewSQLExecutor(db, gloat.WithDialect(gloat.Dialect(driver))),
+		Locker:      locker,
+		LockTimeout: args.lockTimeout,
+		Logger:      log.New(os.Stdout, "", 0),
+		OutOfOrder:  outOfOrder,
+		Vars:        vars,
+		Checksum: gloat.ChecksumOptions{
+			NormalizeLineEndings:   args.normalizeLineEndings,
+			TrimTrailingWhitespace: args.trimTrailingWhitespace,
+		},
+	}
+
+	// Dry runs print the migrations instead of the progress and do not take
+	// the lock, as some lockers write to the database.
+	if args.dryRun {
+		gl.Executor = gloat.NewDryRunExecutor(os.Stdout)
+		gl.Locker = nil
+		gl.Logger = nil
+	}
+
+	return gl, nil
+}
+
+func fileSystemSource(args arguments) gloat.Source {
+	var options []gloat.SourceOption
+	if args.skipUnknown {
+		options = append(options, gloat.WithSkipUnknown())
+	}
+
+	return gloat.NewFileSystemSource(args.src, options...)
+}
+
+// varsFlag collects the repeated -var KEY=VALUE flags.
+type varsFlag gloat.Vars
+
+func (v *varsFlag) String() string {
+	return fmt.Sprint(gloat.Vars(*v))
 }
 
-func databaseStoreFactory(driver string, db *sql.DB) (gloat.Store, error) {
+func (v *varsFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return errors.New("variables must be given as KEY=VALUE")
+	}
+
+	if *v == nil {
+		*v = make(varsFlag)
+	}
+	(*v)[parts[0]] = parts[1]
+
+	return nil
+}
+
+// templateVars merges the variables of the -vars-file with the -var flags,
+// which take precedence.
+func templateVars(args arguments) (gloat.Vars, error) {
+	vars := make(gloat.Vars)
+
+	if args.varsFile != "" {
+		content, err := ioutil.ReadFile(args.varsFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(content, &vars); err != nil {
+			return nil, fmt.Errorf("%s: %v", args.varsFile, err)
+		}
+	}
+
+	for key, value := range args.vars {
+		vars[key] = value
+	}
+
+	return vars, nil
+}
+
+func outOfOrderPolicy(policy string) (gloat.OutOfOrderPolicy, error) {
+	switch policy {
+	case "error":
+		return gloat.RejectOutOfOrder, nil
+	case "warn":
+		return gloat.WarnOutOfOrder, nil
+	case "allow":
+		return gloat.AllowOutOfOrder, nil
+	}
+
+	return "", errors.New("-out-of-order must be error, warn or allow")
+}
+
+// openDatabase connects to a database URL like postgres://localhost/db,
+// mysql://user@tcp(localhost:3306)/db or sqlite3://path/to/file.db.
+func openDatabase(databaseURL string) (string, *sql.DB, error) {
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	driver := u.Scheme
+	dsn := databaseURL
+
 	switch driver {
 	case "postgres", "postgresql":
-		return gloat.NewPostgreSQLStore(db), nil
+		driver = "postgres"
+	case "mysql", "sqlite", "sqlite3":
+		// The MySQL and SQLite3 drivers do not understand URLs, they want
+		// the part after the scheme.
+		parts := strings.SplitN(databaseURL, "://", 2)
+		if len(parts) != 2 {
+			return "", nil, fmt.Errorf("database URL %s must look like %s://...", databaseURL, driver)
+		}
+		dsn = parts[1]
+
+		if driver == "sqlite" {
+			driver = "sqlite3"
+		}
+	default:
+		return "", nil, errors.New("unsupported database driver " + driver)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return driver, db, nil
+}
+
+func databaseStoreFactory(driver string, db *sql.DB, args arguments) (gloat.Store, error) {
+	options := []gloat.StoreOption{gloat.WithTable(args.table)}
+
+	if args.schema != "" {
+		if driver != "postgres" {
+			return nil, errors.New("-schema is supported only for PostgreSQL")
+		}
+
+		options = append(options, gloat.WithSchema(args.schema))
+	}
+
+	switch driver {
+	case "postgres":
+		return gloat.NewPostgreSQLStore(db, options...), nil
+	case "mysql":
+		return gloat.NewMySQLStore(db, options...), nil
+	case "sqlite3":
+		return gloat.NewSQLite3Store(db, options...), nil
+	}
+
+	return nil, errors.New("unsupported database driver " + driver)
+}
+
+func databaseLockerFactory(driver string, db *sql.DB, args arguments) (gloat.Locker, error) {
+	name := args.table
+	if args.schema != "" {
+		name = args.schema + "." + args.table
+	}
+
+	switch driver {
+	case "postgres":
+		return gloat.NewPostgreSQLLocker(db, name), nil
+	case "mysql":
+		return gloat.NewMySQLLocker(db, name), nil
+	case "sqlite3":
+		return gloat.NewSQLite3Locker(db, name), nil
+	}
+
+	return nil, errors.New("unsupported database driver " + driver)
+}
+
+func databaseSchemaDumperFactory(driver string, db *sql.DB) (gloat.SchemaDumper, error) {
+	switch driver {
+	case "postgres":
+		return gloat.NewPostgreSQLSchemaDumper(db), nil
 	case "mysql":
-		return gloat.NewMySQLStore(db), nil
-	case "sqlite", "sqlite3":
-		return gloat.NewMySQLStore(db), nil
+		return gloat.NewMySQLSchemaDumper(db), nil
+	case "sqlite3":
+		return gloat.NewSQLite3SchemaDumper(db), nil
 	}
 
 	return nil, errors.New("unsupported database driver " + driver)
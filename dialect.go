@@ -0,0 +1,75 @@
+package gloat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect is the SQL flavour of a database. It decides how identifiers are
+// quoted, what placeholders look like and the types of the columns gloat
+// creates.
+type Dialect string
+
+// The builtin dialects.
+const (
+	PostgreSQL Dialect = "postgres"
+	MySQL      Dialect = "mysql"
+	SQLite3    Dialect = "sqlite3"
+)
+
+// QuoteIdentifier quotes a table, schema or column name.
+func (d Dialect) QuoteIdentifier(name string) string {
+	if d == MySQL {
+		return "`" + strings.Replace(name, "`", "``", -1) + "`"
+	}
+
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// placeholder returns the n-th, 1-based, bind parameter placeholder.
+func (d Dialect) placeholder(n int) string {
+	if d == PostgreSQL {
+		return fmt.Sprintf("$%d", n)
+	}
+
+	return "?"
+}
+
+func (d Dialect) placeholders(count int) string {
+	placeholders := make([]string, count)
+	for i := range placeholders {
+		placeholders[i] = d.placeholder(i + 1)
+	}
+
+	return strings.Join(placeholders, ", ")
+}
+
+func (d Dialect) timestampType() string {
+	if d == MySQL {
+		return "DATETIME"
+	}
+
+	return "TIMESTAMP"
+}
+
+// quoteString quotes a string literal.
+func (d Dialect) quoteString(value string) string {
+	value = strings.Replace(value, "'", "''", -1)
+	if d == MySQL {
+		value = strings.Replace(value, `\`, `\\`, -1)
+	}
+
+	return "'" + value + "'"
+}
+
+// currentTimestamp is the expression for the current time in UTC.
+func (d Dialect) currentTimestamp() string {
+	switch d {
+	case PostgreSQL:
+		return "(CURRENT_TIMESTAMP AT TIME ZONE 'UTC')"
+	case MySQL:
+		return "UTC_TIMESTAMP()"
+	default:
+		return "CURRENT_TIMESTAMP"
+	}
+}
@@ -0,0 +1,20 @@
+package gloat
+
+import (
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func TestDialectQuoteIdentifier(t *testing.T) {
+	assert.Equal(t, `"schema_migrations"`, PostgreSQL.QuoteIdentifier("schema_migrations"))
+	assert.Equal(t, `"weird""name"`, SQLite3.QuoteIdentifier(`weird"name`))
+	assert.Equal(t, "`schema_migrations`", MySQL.QuoteIdentifier("schema_migrations"))
+	assert.Equal(t, "`weird``name`", MySQL.QuoteIdentifier("weird`name"))
+}
+
+func TestDialectPlaceholders(t *testing.T) {
+	assert.Equal(t, "$1, $2, $3", PostgreSQL.placeholders(3))
+	assert.Equal(t, "?, ?", MySQL.placeholders(2))
+	assert.Equal(t, "?", SQLite3.placeholders(1))
+}
@@ -0,0 +1,83 @@
+package gloat
+
+import (
+	"context"
+	"fmt"
+)
+
+// DirtyError is the error returned when a migration run finds a dirty
+// migration in the store. The database has to be repaired by hand and the
+// migration resolved with Force, before any other migration runs. It matches
+// ErrDirty with errors.Is.
+type DirtyError struct {
+	Version int64
+}
+
+// Error implements the error interface.
+func (err DirtyError) Error() string {
+	return fmt.Sprintf("migration %d is dirty, repair the database by hand and force the migration up or down", err.Version)
+}
+
+// Is reports whether the error matches ErrDirty.
+func (err DirtyError) Is(target error) bool {
+	return target == ErrDirty
+}
+
+// Force resolves the state of a migration after the database was repaired by
+// hand. Forcing a migration up records it as applied and clean. Forcing it down
+// removes it from the store. The migration SQL is not executed.
+func (c *Gloat) Force(version int64, direction Direction) error {
+	return c.ForceContext(context.Background(), version, direction)
+}
+
+// ForceContext resolves the state of a migration after the database was
+// repaired by hand. See Force for the details.
+func (c *Gloat) ForceContext(ctx context.Context, version int64, direction Direction) error {
+	return c.withLock(ctx, func() error {
+		appliedMigrations, availableMigrations, err := c.collectBoth(ctx)
+		if err != nil {
+			return err
+		}
+
+		migration := availableMigrations.Find(version)
+		if migration == nil {
+			migration = appliedMigrations.Find(version)
+		}
+		if migration == nil {
+			return fmt.Errorf("cannot force unknown version %d", version)
+		}
+
+		applied := appliedMigrations.Find(version)
+
+		if err := setup(ctx, c.Store); err != nil {
+			return err
+		}
+
+		switch {
+		case direction == Down && applied != nil:
+			return remove(ctx, c.Store, migration, nil)
+		case direction == Down:
+			return nil
+		case applied == nil:
+			migration.Checksum = c.Checksum.Sum(migration.UpSQL)
+			return insert(ctx, c.Store, migration, nil)
+		}
+
+		if store, ok := c.Store.(DirtyStore); ok && applied.Dirty {
+			return store.SetDirty(ctx, migration, false, nil)
+		}
+
+		return nil
+	})
+}
+
+// dirty returns a DirtyError for the first dirty migration.
+func dirty(appliedMigrations Migrations) error {
+	for _, migration := range appliedMigrations {
+		if migration.Dirty {
+			return DirtyError{migration.Version}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package gloat
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func TestSQLExecutor_Up_Dirty(t *testing.T) {
+	migration := &Migration{
+		UpSQL:   []byte("CREATE TABLE users (id INTEGER);\nCREATE TABL broken (id INTEGER);"),
+		DownSQL: []byte("DROP TABLE users;"),
+		Path:    "20180905150724_half_broken",
+		Version: 20180905150724,
+		Options: MigrationOptions{Transaction: false, Split: true},
+	}
+
+	dbStore, err := databaseStoreFactory(dbDriver, db)
+	assert.Nil(t, err)
+
+	exe := NewSQLExecutor(db, WithDialect(Dialect(dbDriver)))
+
+	cleanState(func() {
+		err := exe.Up(migration, dbStore)
+		assert.True(t, errors.Is(err, ErrDirty))
+
+		migrations, err := dbStore.Collect()
+		assert.Nil(t, err)
+
+		assert.Len(t, 1, migrations)
+		assert.Equal(t, 20180905150724, migrations[0].Version)
+		assert.True(t, migrations[0].Dirty)
+	})
+}
+
+func TestSQLExecutor_Up_NonTransactionalClean(t *testing.T) {
+	migration := &Migration{
+		UpSQL:   []byte("CREATE TABLE users (id INTEGER);"),
+		DownSQL: []byte("DROP TABLE users;"),
+		Path:    "20180905150724_add_users",
+		Version: 20180905150724,
+		Options: MigrationOptions{Transaction: false, Split: true},
+	}
+
+	dbStore, err := databaseStoreFactory(dbDriver, db)
+	assert.Nil(t, err)
+
+	exe := NewSQLExecutor(db)
+
+	cleanState(func() {
+		err := exe.Up(migration, dbStore)
+		assert.Nil(t, err)
+
+		migrations, err := dbStore.Collect()
+		assert.Nil(t, err)
+
+		assert.Len(t, 1, migrations)
+		assert.False(t, migrations[0].Dirty)
+		assert.Equal(t, "add_users", migrations[0].Name())
+
+		err = exe.Down(migration, dbStore)
+		assert.Nil(t, err)
+
+		migrations, err = dbStore.Collect()
+		assert.Nil(t, err)
+		assert.Len(t, 0, migrations)
+	})
+}
+
+func TestPlanTo_Dirty(t *testing.T) {
+	gl.Store = &testingStore{
+		applied: Migrations{
+			&Migration{Version: 20170329154959},
+			&Migration{Version: 20170511172647, Dirty: true},
+		},
+	}
+
+	_, err := gl.PlanTo(Latest)
+	assert.Equal(t, DirtyError{20170511172647}, err)
+	assert.True(t, errors.Is(err, ErrDirty))
+
+	_, err = gl.PlanSteps(-1)
+	assert.Equal(t, DirtyError{20170511172647}, err)
+
+	_, err = gl.Unapplied()
+	assert.Equal(t, DirtyError{20170511172647}, err)
+
+	status, err := gl.Status()
+	assert.Nil(t, err)
+	assert.Len(t, 1, status.Dirty())
+	assert.False(t, status.Clean())
+}
+
+func TestForce(t *testing.T) {
+	dbStore, err := databaseStoreFactory(dbDriver, db)
+	assert.Nil(t, err)
+
+	gl.Store = dbStore
+	defer func() { gl.Store = new(testingStore) }()
+
+	cleanState(func() {
+		migration := &Migration{Path: "20180905150724_concurrent_migration", Version: 20180905150724}
+
+		err := dbStore.(SetupStore).Setup(context.Background())
+		assert.Nil(t, err)
+
+		err = dbStore.(DirtyStore).SetDirty(context.Background(), migration, true, nil)
+		assert.Nil(t, err)
+
+		_, err = gl.PlanTo(Latest)
+		assert.Equal(t, DirtyError{20180905150724}, err)
+
+		err = gl.Force(20180905150724, Up)
+		assert.Nil(t, err)
+
+		migrations, err := dbStore.Collect()
+		assert.Nil(t, err)
+		assert.Len(t, 1, migrations)
+		assert.False(t, migrations[0].Dirty)
+
+		err = gl.Force(20180905150724, Down)
+		assert.Nil(t, err)
+
+		migrations, err = dbStore.Collect()
+		assert.Nil(t, err)
+		assert.Len(t, 0, migrations)
+
+		err = gl.Force(20170329154959, Up)
+		assert.Nil(t, err)
+
+		migrations, err = dbStore.Collect()
+		assert.Nil(t, err)
+		assert.Len(t, 1, migrations)
+		assert.Equal(t, 20170329154959, migrations[0].Version)
+
+		assert.Error(t, gl.Force(42, Up))
+	})
+}
@@ -0,0 +1,119 @@
+package gloat
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// DryRunExecutor is an Executor that prints the migrations instead of
+// executing them. For every migration, it prints its version, whether it runs
+// in a transaction, its SQL and, if the store is a StatementStore, the
+// statements that record it. Nothing is executed against the database.
+type DryRunExecutor struct {
+	w io.Writer
+}
+
+// Up prints the migration UpSQL.
+func (e *DryRunExecutor) Up(migration *Migration, store Store) error {
+	return e.UpContext(context.Background(), migration, store)
+}
+
+// UpContext prints the migration UpSQL.
+func (e *DryRunExecutor) UpContext(_ context.Context, migration *Migration, store Store) error {
+	var bookkeeping string
+	if store, ok := store.(StatementStore); ok {
+		bookkeeping = store.InsertStatement(migration)
+	}
+
+	return writeMigration(e.w, migration, Up, bookkeeping)
+}
+
+// Down prints the migration DownSQL.
+func (e *DryRunExecutor) Down(migration *Migration, store Store) error {
+	return e.DownContext(context.Background(), migration, store)
+}
+
+// DownContext prints the migration DownSQL.
+func (e *DryRunExecutor) DownContext(_ context.Context, migration *Migration, store Store) error {
+	if !migration.Reversible() {
+		return IrreversibleError{migration.Version}
+	}
+
+	var bookkeeping string
+	if store, ok := store.(StatementStore); ok {
+		bookkeeping = store.RemoveStatement(migration)
+	}
+
+	return writeMigration(e.w, migration, Down, bookkeeping)
+}
+
+// NewDryRunExecutor creates a DryRunExecutor printing to w.
+func NewDryRunExecutor(w io.Writer) Executor {
+	return &DryRunExecutor{w: w}
+}
+
+// writeMigration writes one side of a migration as an SQL script, followed
+// by the statement recording it in the store.
+func writeMigration(w io.Writer, migration *Migration, direction Direction, bookkeeping string) error {
+	content, fn := migration.UpSQL, migration.UpFunc
+	if direction == Down {
+		content, fn = migration.DownSQL, migration.DownFunc
+	}
+
+	mode := "in a transaction"
+	if !migration.Options.Transaction {
+		mode = "without a transaction"
+	}
+
+	var script bytes.Buffer
+
+	fmt.Fprintf(&script, "-- Migration %d %s (%s, %s)\n", migration.Version, migration.Name(), direction, mode)
+	if migration.Options.Transaction {
+		fmt.Fprintf(&script, "BEGIN;\n")
+	}
+
+	if fn != nil {
+		fmt.Fprintf(&script, "-- Written in Go, its SQL cannot be shown.\n")
+	} else {
+		fmt.Fprintf(&script, "%s\n", terminateStatement(content))
+	}
+
+	if bookkeeping != "" {
+		fmt.Fprintf(&script, "%s\n", bookkeeping)
+	}
+
+	if migration.Options.Transaction {
+		fmt.Fprintf(&script, "COMMIT;\n")
+	}
+	fmt.Fprintf(&script, "\n")
+
+	_, err := script.WriteTo(w)
+	return err
+}
+
+// terminateStatement makes sure the last statement of a migration ends with a
+// semicolon, so the statements written after it are not merged with it. A
+// statement ending with a line comment gets the semicolon on a line of its
+// own, so it does not end up in the comment.
+func terminateStatement(content []byte) []byte {
+	content = bytes.TrimRight(content, " \t\r\n")
+
+	statements, err := SplitStatements(content, "")
+	if err != nil || len(statements) == 0 {
+		return content
+	}
+
+	last := statements[len(statements)-1]
+	if rest := bytes.TrimLeft(content[last.Offset+len(last.SQL):], " \t\r\n"); bytes.HasPrefix(rest, []byte(";")) {
+		return content
+	}
+
+	lastLine := content[bytes.LastIndexByte(content, '\n')+1:]
+	if bytes.Contains(lastLine, []byte("--")) {
+		return append(content[:len(content):len(content)], '\n', ';')
+	}
+
+	return append(content[:len(content):len(content)], ';')
+}
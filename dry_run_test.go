@@ -0,0 +1,87 @@
+package gloat
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func TestDryRunExecutor_Up(t *testing.T) {
+	td := filepath.Join(dbSrc, "20170329154959_introduce_domain_model")
+
+	migration, err := MigrationFromBytes(td, ioutil.ReadFile)
+	assert.Nil(t, err)
+
+	dbStore, err := databaseStoreFactory(dbDriver, db)
+	assert.Nil(t, err)
+
+	var out bytes.Buffer
+
+	cleanState(func() {
+		err := NewDryRunExecutor(&out).Up(migration, dbStore)
+		assert.Nil(t, err)
+
+		script := out.String()
+		assert.True(t, strings.HasPrefix(script, "-- Migration 20170329154959 introduce_domain_model (up, in a transaction)\nBEGIN;\n"))
+		assert.True(t, strings.Contains(script, strings.TrimSpace(string(migration.UpSQL))))
+		assert.True(t, strings.Contains(script, "INSERT INTO "))
+		assert.True(t, strings.HasSuffix(script, "COMMIT;\n\n"))
+
+		migrations, err := dbStore.Collect()
+		assert.Nil(t, err)
+		assert.Len(t, 0, migrations)
+
+		_, err = db.Exec(`SELECT id FROM users LIMIT 1`)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestDryRunExecutor_Down(t *testing.T) {
+	migration := &Migration{
+		DownSQL: []byte("DROP TABLE users"),
+		Path:    "20170329154959_introduce_domain_model",
+		Version: 20170329154959,
+		Options: MigrationOptions{Transaction: false},
+	}
+
+	var out bytes.Buffer
+
+	err := NewDryRunExecutor(&out).Down(migration, new(testingStore))
+	assert.Nil(t, err)
+
+	assert.Equal(t, "-- Migration 20170329154959 introduce_domain_model (down, without a transaction)\nDROP TABLE users;\n\n", out.String())
+
+	err = NewDryRunExecutor(&out).Down(&Migration{Version: 20170329154959}, new(testingStore))
+	assert.Equal(t, IrreversibleError{20170329154959}, err)
+}
+
+func TestDryRunExecutor_GoMigration(t *testing.T) {
+	migration := &Migration{
+		Path:    "20170329154959_backfill",
+		Version: 20170329154959,
+		Options: DefaultMigrationOptions(),
+		UpFunc:  func(context.Context, SQLExecer) error { return nil },
+	}
+
+	var out bytes.Buffer
+
+	err := NewDryRunExecutor(&out).Up(migration, new(testingStore))
+	assert.Nil(t, err)
+
+	assert.True(t, strings.Contains(out.String(), "-- Written in Go"))
+}
+
+func TestTerminateStatement(t *testing.T) {
+	assert.Equal(t, "SELECT 1;", string(terminateStatement([]byte("SELECT 1\n\n"))))
+	assert.Equal(t, "SELECT 1;", string(terminateStatement([]byte("SELECT 1;\n"))))
+	assert.Equal(t, "SELECT 1;\n-- done", string(terminateStatement([]byte("SELECT 1;\n-- done\n"))))
+	assert.Equal(t, "", string(terminateStatement(nil)))
+	assert.Equal(t, "CREATE TABLE a (id int)\n-- trailing note\n;", string(terminateStatement([]byte("CREATE TABLE a (id int)\n-- trailing note\n"))))
+	assert.Equal(t, "SELECT 1 -- note\n;", string(terminateStatement([]byte("SELECT 1 -- note"))))
+	assert.Equal(t, "SELECT 1 -- note;\n;", string(terminateStatement([]byte("SELECT 1 -- note;"))))
+}
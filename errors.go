@@ -0,0 +1,241 @@
+package gloat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrNoCurrentMigration is the error returned when reverting, while there
+	// are no applied migrations.
+	ErrNoCurrentMigration = errors.New("no current migration")
+
+	// ErrDirty is matched by errors.Is, when a migration failed halfway
+	// outside of a transaction and may have left partial changes behind.
+	ErrDirty = errors.New("database is dirty")
+)
+
+// MigrationError is the error returned when the SQL or the Go function of a
+// migration fails. It points to the failing statement and, when the driver
+// reports it, to the failing line and column of the migration file.
+type MigrationError struct {
+	Version   int64
+	Path      string
+	Direction Direction
+
+	// StatementIndex is the 0-based index of the failing statement, as split
+	// by SplitStatements. It is -1 for migrations written in Go.
+	StatementIndex int
+
+	// Statement is the text of the failing statement. The whole migration, if
+	// it was not split.
+	Statement string
+
+	// Line and Column are the 1-based position of the error in the migration
+	// file. Zero if unknown. When the driver does not report a position, they
+	// point to the start of the failing statement.
+	Line   int
+	Column int
+
+	// Dirty is true for migrations that run outside of a transaction, as the
+	// statements before the failing one are not rolled back.
+	Dirty bool
+
+	// Err is the underlying driver or Go function error.
+	Err error
+
+	statementLine int
+}
+
+// Error implements the error interface.
+func (err MigrationError) Error() string {
+	var location string
+	if err.Line != 0 {
+		location = fmt.Sprintf(" at %s:%d:%d", err.Path, err.Line, err.Column)
+	} else if err.Path != "" {
+		location = fmt.Sprintf(" at %s", err.Path)
+	}
+
+	return fmt.Sprintf("migration %d %s failed%s: %v", err.Version, err.Direction, location, err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err MigrationError) Unwrap() error {
+	return err.Err
+}
+
+// Is reports whether the error matches ErrDirty.
+func (err MigrationError) Is(target error) bool {
+	return target == ErrDirty && err.Dirty
+}
+
+// Frame renders the failing statement with line numbers and a marker under
+// the position of the error, if the driver reported one:
+//
+//	  1 | CREATE TABL users (
+//	> 2 |     id bigserial PRIMARY KEY NOT NULL
+//	    |     ^
+//	  3 | );
+func (err MigrationError) Frame() string {
+	if err.Statement == "" {
+		return ""
+	}
+
+	lines := strings.Split(err.Statement, "\n")
+	first := err.statementLine
+	if first == 0 {
+		first = 1
+	}
+
+	errorLine := err.Line
+	if errorLine < first || errorLine >= first+len(lines) {
+		errorLine = 0
+	}
+
+	from, to := first, first+len(lines)-1
+	if errorLine != 0 {
+		from, to = maxInt(from, errorLine-2), minInt(to, errorLine+2)
+	} else {
+		to = minInt(to, first+4)
+	}
+
+	width := len(strconv.Itoa(to))
+
+	var frame bytes.Buffer
+	for line := from; line <= to; line++ {
+		marker := " "
+		if line == errorLine {
+			marker = ">"
+		}
+
+		text := strings.TrimRight(lines[line-first], "\r")
+		fmt.Fprintf(&frame, "%s %*d | %s\n", marker, width, line, text)
+
+		if line == errorLine && err.Column > 0 {
+			fmt.Fprintf(&frame, "  %*s | %s^\n", width, "", caretPadding(text, err.Column))
+		}
+	}
+
+	return frame.String()
+}
+
+// newMigrationError builds a MigrationError for a failure in a statement at
+// the given byte offset of the migration content.
+func newMigrationError(migration *Migration, direction Direction, err error, index int, statement Statement, content []byte) MigrationError {
+	path, lineOffset := migration.sectionPath(direction)
+
+	migrationErr := MigrationError{
+		Version:        migration.Version,
+		Path:           path,
+		Direction:      direction,
+		StatementIndex: index,
+		Statement:      statement.SQL,
+		Dirty:          !migration.Options.Transaction,
+		Err:            err,
+	}
+
+	if index < 0 {
+		return migrationErr
+	}
+
+	offset := statement.Offset
+	if position := errorPosition(err, statement.SQL); position >= 0 {
+		offset += position
+	}
+
+	migrationErr.statementLine, _ = lineAndColumn(content, statement.Offset)
+	migrationErr.statementLine += lineOffset
+
+	migrationErr.Line, migrationErr.Column = lineAndColumn(content, offset)
+	migrationErr.Line += lineOffset
+
+	return migrationErr
+}
+
+var mysqlSyntaxErrorRe = regexp.MustCompile(`You have an error in your SQL syntax.* at line (\d+)$`)
+
+// errorPosition extracts the byte offset of an error in a statement. The
+// PostgreSQL driver reports the character position of syntax errors, while
+// MySQL reports the line in the message. Returns -1 if unknown.
+func errorPosition(err error, statement string) int {
+	var fielder interface{ Get(byte) string }
+	if errors.As(err, &fielder) {
+		if position, convErr := strconv.Atoi(fielder.Get('P')); convErr == nil && position > 0 {
+			offset := 0
+			for i := 1; i < position && offset < len(statement); i++ {
+				_, size := utf8.DecodeRuneInString(statement[offset:])
+				offset += size
+			}
+
+			return offset
+		}
+	}
+
+	if match := mysqlSyntaxErrorRe.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+
+		offset := 0
+		for i := 1; i < line; i++ {
+			next := strings.IndexByte(statement[offset:], '\n')
+			if next < 0 {
+				break
+			}
+			offset += next + 1
+		}
+
+		return offset
+	}
+
+	return -1
+}
+
+func lineAndColumn(content []byte, offset int) (line, column int) {
+	if offset > len(content) {
+		offset = len(content)
+	}
+
+	lineStart := bytes.LastIndexByte(content[:offset], '\n') + 1
+
+	line = bytes.Count(content[:offset], []byte("\n")) + 1
+	column = utf8.RuneCount(content[lineStart:offset]) + 1
+
+	return
+}
+
+func caretPadding(line string, column int) string {
+	var padding strings.Builder
+	for i, r := range line {
+		if utf8.RuneCountInString(line[:i]) >= column-1 {
+			break
+		}
+
+		if r == '\t' {
+			padding.WriteRune('\t')
+		} else {
+			padding.WriteRune(' ')
+		}
+	}
+
+	return padding.String()
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,102 @@
+package gloat
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+type fieldError struct {
+	fields map[byte]string
+}
+
+func (err fieldError) Error() string         { return "syntax error" }
+func (err fieldError) Get(field byte) string { return err.fields[field] }
+
+func TestSQLExecutor_Up_MigrationError(t *testing.T) {
+	td := filepath.Join(dbSrc, "20180920181906_migration_with_an_error")
+
+	exe := NewSQLExecutor(db, WithDialect(Dialect(dbDriver)))
+
+	migration, err := MigrationFromBytes(td, ioutil.ReadFile)
+	assert.Nil(t, err)
+
+	cleanState(func() {
+		err := exe.Up(migration, new(testingStore))
+
+		var migrationErr MigrationError
+		assert.True(t, errors.As(err, &migrationErr))
+
+		assert.Equal(t, 20180920181906, migrationErr.Version)
+		assert.Equal(t, filepath.Join(td, "up.sql"), migrationErr.Path)
+		assert.Equal(t, Up, migrationErr.Direction)
+		assert.Equal(t, 0, migrationErr.StatementIndex)
+		assert.Equal(t, 1, migrationErr.Line)
+		assert.NotNil(t, migrationErr.Err)
+		assert.False(t, errors.Is(err, ErrDirty))
+	})
+}
+
+func TestSQLExecutor_Up_GoMigrationError(t *testing.T) {
+	failure := errors.New("failure")
+
+	migration := &Migration{
+		Path:    "20180905150724_backfill",
+		Version: 20180905150724,
+		Options: MigrationOptions{Transaction: false},
+		UpFunc:  func(_ context.Context, _ SQLExecer) error { return failure },
+	}
+
+	err := NewSQLExecutor(db).Up(migration, new(testingStore))
+	assert.True(t, errors.Is(err, failure))
+	assert.True(t, errors.Is(err, ErrDirty))
+
+	var migrationErr MigrationError
+	assert.True(t, errors.As(err, &migrationErr))
+	assert.Equal(t, -1, migrationErr.StatementIndex)
+	assert.Equal(t, 0, migrationErr.Line)
+}
+
+func TestNewMigrationError_Position(t *testing.T) {
+	content := []byte("-- gloat:up\nCREATE TABLE users ();\n\nCREATE TABL posts (\n  id int\n);\n")
+
+	migration, err := MigrationFromFile("20180905150724_add_posts.sql", func(string) ([]byte, error) {
+		return content, nil
+	})
+	assert.Nil(t, err)
+
+	statements, err := SplitStatements(migration.UpSQL, PostgreSQL)
+	assert.Nil(t, err)
+
+	driverErr := fieldError{map[byte]string{'P': "8"}}
+	migrationErr := newMigrationError(migration, Up, driverErr, 1, statements[1], migration.UpSQL)
+
+	assert.Equal(t, "20180905150724_add_posts.sql", migrationErr.Path)
+	assert.Equal(t, 4, migrationErr.Line)
+	assert.Equal(t, 8, migrationErr.Column)
+	assert.Equal(t, "migration 20180905150724 up failed at 20180905150724_add_posts.sql:4:8: syntax error", migrationErr.Error())
+	assert.Equal(t, "> 4 | CREATE TABL posts (\n    |        ^\n  5 |   id int\n  6 | )\n", migrationErr.Frame())
+}
+
+func TestNewMigrationError_MySQLLine(t *testing.T) {
+	content := []byte("CREATE TABLE posts (\n  id int,\n  SELEC\n);")
+	driverErr := errors.New("Error 1064: You have an error in your SQL syntax; check the manual near 'SELEC' at line 3")
+
+	migration := &Migration{Path: "20180905150724_add_posts", Version: 20180905150724}
+	migrationErr := newMigrationError(migration, Down, driverErr, 0, Statement{SQL: string(content)}, content)
+
+	assert.Equal(t, filepath.Join("20180905150724_add_posts", "down.sql"), migrationErr.Path)
+	assert.Equal(t, 3, migrationErr.Line)
+	assert.Equal(t, 1, migrationErr.Column)
+}
+
+func TestPlanSteps_NoCurrentMigration(t *testing.T) {
+	gl.Store = &testingStore{applied: Migrations{}}
+
+	_, err := gl.PlanSteps(-1)
+	assert.Equal(t, ErrNoCurrentMigration, err)
+}
@@ -1,7 +1,9 @@
 package gloat
 
 import (
+	"context"
 	"fmt"
+	"time"
 )
 
 // IrreversibleError is the error return when we're trying to reverse a
@@ -15,49 +17,189 @@ func (err IrreversibleError) Error() string {
 	return fmt.Sprintf("cannot reverse migration %d", err.Version)
 }
 
+// MissingMigrationError is the error returned when a migration is recorded as
+// applied in the store, but is no longer available from the source.
+type MissingMigrationError struct {
+	Version int64
+}
+
+// Error implements the error interface.
+func (err MissingMigrationError) Error() string {
+	return fmt.Sprintf("migration %d is applied, but missing from the source", err.Version)
+}
+
 // Executor is a type that executes migrations up and down.
 type Executor interface {
 	Up(*Migration, Store) error
 	Down(*Migration, Store) error
 }
 
+// ContextExecutor is an Executor that can be cancelled through a context. The
+// builtin SQLExecutor implements it. Executors that do not are still usable,
+// they just run to completion regardless of the context.
+type ContextExecutor interface {
+	Executor
+
+	UpContext(context.Context, *Migration, Store) error
+	DownContext(context.Context, *Migration, Store) error
+}
+
 // SQLExecutor is a type that executes migrations in a database.
+//
+// If the executor knows the dialect of the database, it splits the migrations
+// into statements and runs them one by one. Otherwise, or if a migration turns
+// the Split option off, the whole migration is sent to the database at once.
 type SQLExecutor struct {
-	db SQLTransactor
+	db      SQLTransactor
+	dialect Dialect
+}
+
+// ExecutorOption configures an SQLExecutor.
+type ExecutorOption func(*SQLExecutor)
+
+// WithDialect sets the SQL dialect the migrations are split by.
+func WithDialect(dialect Dialect) ExecutorOption {
+	return func(e *SQLExecutor) { e.dialect = dialect }
 }
 
 // Up applies a migration.
 func (e *SQLExecutor) Up(migration *Migration, store Store) error {
-	return e.exec(migration.Options.Transaction, func(tx SQLExecer) error {
-		if _, err := tx.Exec(string(migration.UpSQL)); err != nil {
+	return e.UpContext(context.Background(), migration, store)
+}
+
+// UpContext applies a migration. If the context is cancelled while the
+// migration runs, its transaction is rolled back. Migrations running outside
+// of a transaction are recorded as dirty until they succeed, if the store is a
+// DirtyStore.
+func (e *SQLExecutor) UpContext(ctx context.Context, migration *Migration, store Store) error {
+	if err := setup(ctx, store); err != nil {
+		return err
+	}
+
+	if store, ok := store.(DirtyStore); ok && !migration.Options.Transaction {
+		return e.upDirty(ctx, migration, store)
+	}
+
+	return e.exec(ctx, migration.Options.Transaction, func(tx SQLExecer) error {
+		start := time.Now()
+
+		if err := e.run(ctx, tx, migration, Up, migration.UpSQL, migration.UpFunc); err != nil {
 			return err
 		}
 
-		return store.Insert(migration, tx)
+		migration.Duration = time.Since(start)
+
+		return insert(ctx, store, migration, tx)
 	})
 }
 
 // Down reverses a migrations.
 func (e *SQLExecutor) Down(migration *Migration, store Store) error {
+	return e.DownContext(context.Background(), migration, store)
+}
+
+// DownContext reverses a migration. If the context is cancelled while the
+// migration runs, its transaction is rolled back. Migrations running outside
+// of a transaction are marked as dirty until they succeed, if the store is a
+// DirtyStore.
+func (e *SQLExecutor) DownContext(ctx context.Context, migration *Migration, store Store) error {
 	if !migration.Reversible() {
 		return IrreversibleError{migration.Version}
 	}
 
-	return e.exec(migration.Options.Transaction, func(tx SQLExecer) error {
-		if _, err := tx.Exec(string(migration.DownSQL)); err != nil {
+	if err := setup(ctx, store); err != nil {
+		return err
+	}
+
+	if store, ok := store.(DirtyStore); ok && !migration.Options.Transaction {
+		return e.downDirty(ctx, migration, store)
+	}
+
+	return e.exec(ctx, migration.Options.Transaction, func(tx SQLExecer) error {
+		if err := e.run(ctx, tx, migration, Down, migration.DownSQL, migration.DownFunc); err != nil {
 			return err
 		}
 
-		return store.Remove(migration, tx)
+		return remove(ctx, store, migration, tx)
 	})
 }
 
-func (e *SQLExecutor) exec(transaction bool, action func(SQLExecer) error) error {
+// run executes one side of a migration. The errors are wrapped in
+// MigrationError, pointing to the failing statement.
+func (e *SQLExecutor) run(ctx context.Context, tx SQLExecer, migration *Migration, direction Direction, content []byte, fn MigrationFunc) error {
+	if fn != nil {
+		if err := fn(ctx, tx); err != nil {
+			return newMigrationError(migration, direction, err, -1, Statement{}, nil)
+		}
+
+		return nil
+	}
+
+	if e.dialect == "" || !migration.Options.Split {
+		statement := Statement{SQL: string(content)}
+		if _, err := execContext(ctx, tx, statement.SQL); err != nil {
+			return newMigrationError(migration, direction, err, 0, statement, content)
+		}
+
+		return nil
+	}
+
+	statements, err := SplitStatements(content, e.dialect)
+	if err != nil {
+		return newMigrationError(migration, direction, err, -1, Statement{}, nil)
+	}
+
+	for i, statement := range statements {
+		if _, err := execContext(ctx, tx, statement.SQL); err != nil {
+			return newMigrationError(migration, direction, err, i, statement, content)
+		}
+	}
+
+	return nil
+}
+
+func (e *SQLExecutor) upDirty(ctx context.Context, migration *Migration, store DirtyStore) error {
+	if err := store.SetDirty(ctx, migration, true, e.db); err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	if err := e.run(ctx, e.db, migration, Up, migration.UpSQL, migration.UpFunc); err != nil {
+		return err
+	}
+
+	migration.Duration = time.Since(start)
+
+	// Record the migration again with its duration and without the dirty
+	// flag. Both happen at once, so it cannot go missing in between.
+	return e.exec(ctx, true, func(tx SQLExecer) error {
+		if err := remove(ctx, store, migration, tx); err != nil {
+			return err
+		}
+
+		return insert(ctx, store, migration, tx)
+	})
+}
+
+func (e *SQLExecutor) downDirty(ctx context.Context, migration *Migration, store DirtyStore) error {
+	if err := store.SetDirty(ctx, migration, true, e.db); err != nil {
+		return err
+	}
+
+	if err := e.run(ctx, e.db, migration, Down, migration.DownSQL, migration.DownFunc); err != nil {
+		return err
+	}
+
+	return remove(ctx, store, migration, e.db)
+}
+
+func (e *SQLExecutor) exec(ctx context.Context, transaction bool, action func(SQLExecer) error) error {
 	if !transaction {
 		return action(e.db)
 	}
 
-	tx, err := e.db.Begin()
+	tx, err := beginTx(ctx, e.db)
 	if err != nil {
 		return err
 	}
@@ -71,6 +213,28 @@ func (e *SQLExecutor) exec(transaction bool, action func(SQLExecer) error) error
 }
 
 // NewSQLExecutor creates an SQLExecutor.
-func NewSQLExecutor(db SQLTransactor) Executor {
-	return &SQLExecutor{db: db}
+func NewSQLExecutor(db SQLTransactor, options ...ExecutorOption) Executor {
+	e := &SQLExecutor{db: db}
+
+	for _, option := range options {
+		option(e)
+	}
+
+	return e
+}
+
+func up(ctx context.Context, executor Executor, migration *Migration, store Store) error {
+	if executor, ok := executor.(ContextExecutor); ok {
+		return executor.UpContext(ctx, migration, store)
+	}
+
+	return executor.Up(migration, store)
+}
+
+func down(ctx context.Context, executor Executor, migration *Migration, store Store) error {
+	if executor, ok := executor.(ContextExecutor); ok {
+		return executor.DownContext(ctx, migration, store)
+	}
+
+	return executor.Down(migration, store)
 }
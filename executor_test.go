@@ -1,7 +1,10 @@
 package gloat
 
 import (
+	"context"
+	"database/sql"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -72,3 +75,51 @@ func TestSQLExecutor_Down_Broken(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestSQLExecutor_UpContext_Cancelled(t *testing.T) {
+	td := filepath.Join(dbSrc, "20170329154959_introduce_domain_model")
+
+	exe := NewSQLExecutor(db).(ContextExecutor)
+
+	migration, err := MigrationFromBytes(td, ioutil.ReadFile)
+	assert.Nil(t, err)
+
+	cleanState(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := exe.UpContext(ctx, migration, new(testingStore))
+		assert.Equal(t, context.Canceled, err)
+
+		_, err = db.Exec(`SELECT id FROM users LIMIT 1`)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestSQLExecutor_Up_CreatesTheStoreTableFirst(t *testing.T) {
+	if dbDriver != "sqlite3" {
+		t.Skip("the connection locking is specific to SQLite3 files")
+	}
+
+	dir, err := ioutil.TempDir("", "gloat")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fileDB, err := sql.Open("sqlite3", filepath.Join(dir, "gloat.db"))
+	assert.Nil(t, err)
+	defer fileDB.Close()
+
+	td := filepath.Join(dbSrc, "20170329154959_introduce_domain_model")
+
+	migration, err := MigrationFromBytes(td, ioutil.ReadFile)
+	assert.Nil(t, err)
+
+	store := NewSQLite3Store(fileDB)
+
+	err = NewSQLExecutor(fileDB).Up(migration, store)
+	assert.Nil(t, err)
+
+	migrations, err := store.Collect()
+	assert.Nil(t, err)
+	assert.Len(t, 1, migrations)
+}
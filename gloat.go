@@ -1,6 +1,14 @@
 package gloat
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Version is the version of gloat. The database stores record it next to every
+// applied migration.
+const Version = "0.2.0"
 
 // Gloat glues all the components needed to apply and revert
 // migrations.
@@ -16,11 +24,60 @@ type Gloat struct {
 	// Executor applies migrations and marks the newly applied migration
 	// versions in the Store.
 	Executor Executor
+
+	// Checksum configures the normalization of the UpSQL content before it is
+	// hashed on Apply and Verify.
+	Checksum ChecksumOptions
+
+	// Locker, if set, guards the migration runs, like MigrateTo and Steps,
+	// against concurrent runs from other processes.
+	Locker Locker
+
+	// LockTimeout bounds the time spent waiting for the Locker. Zero means
+	// waiting for as long as the run context allows.
+	LockTimeout time.Duration
+
+	// Logger reports the progress of the migration runs, like MigrateTo and
+	// Steps. Can be nil.
+	Logger Logger
+
+	// OutOfOrder decides what to do with unapplied migrations older than the
+	// latest applied one. By default, they fail Unapplied and the migration
+	// runs with an OutOfOrderError.
+	OutOfOrder OutOfOrderPolicy
+
+	// Vars are the variables migrations with the Template option are
+	// rendered with.
+	Vars Vars
 }
 
-// Unapplied returns the unapplied migrations in the current gloat.
+// Unapplied returns the unapplied migrations in the current gloat. See
+// UnappliedContext for the details.
 func (c *Gloat) Unapplied() (Migrations, error) {
-	return UnappliedMigrations(c.Store, c.Source)
+	return c.UnappliedContext(context.Background())
+}
+
+// UnappliedContext returns the unapplied migrations in the current gloat.
+// Unapplied migrations older than the latest applied one are subject to the
+// OutOfOrder policy. A dirty migration in the store fails with a DirtyError.
+func (c *Gloat) UnappliedContext(ctx context.Context) (Migrations, error) {
+	appliedMigrations, availableMigrations, err := c.collectBoth(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dirty(appliedMigrations); err != nil {
+		return nil, err
+	}
+
+	unappliedMigrations := appliedMigrations.Except(availableMigrations)
+	unappliedMigrations.Sort()
+
+	if err := c.checkOutOfOrder(appliedMigrations, unappliedMigrations, Latest); err != nil {
+		return nil, err
+	}
+
+	return unappliedMigrations, nil
 }
 
 // Current returns the latest applied migration. Even if no error is returned,
@@ -29,7 +86,13 @@ func (c *Gloat) Unapplied() (Migrations, error) {
 // This is the case when the last applied migration is no longer available from
 // the source or there are no migrations to begin with.
 func (c *Gloat) Current() (*Migration, error) {
-	appliedMigrations, err := c.Store.Collect()
+	return c.CurrentContext(context.Background())
+}
+
+// CurrentContext returns the latest applied migration. See Current for the
+// details.
+func (c *Gloat) CurrentContext(ctx context.Context) (*Migration, error) {
+	appliedMigrations, err := collect(ctx, c.Store)
 	if err != nil {
 		return nil, err
 	}
@@ -39,7 +102,7 @@ func (c *Gloat) Current() (*Migration, error) {
 		return nil, nil
 	}
 
-	availableMigrations, err := c.Source.Collect()
+	availableMigrations, err := collect(ctx, c.Source)
 	if err != nil {
 		return nil, err
 	}
@@ -57,12 +120,53 @@ func (c *Gloat) Current() (*Migration, error) {
 
 // Apply applies a migration.
 func (c *Gloat) Apply(migration *Migration) error {
-	return c.Executor.Up(migration, c.Store)
+	return c.ApplyContext(context.Background(), migration)
+}
+
+// ApplyContext applies a migration. If the Executor supports it, the context
+// can cancel the migration while it runs.
+func (c *Gloat) ApplyContext(ctx context.Context, migration *Migration) error {
+	if migration == nil {
+		return up(ctx, c.Executor, migration, c.Store)
+	}
+
+	migration.Checksum = c.Checksum.Sum(migration.UpSQL)
+
+	rendered, err := migration.Render(c.Vars, Up)
+	if err != nil {
+		return err
+	}
+
+	return c.apply(ctx, migration, rendered)
+}
+
+// apply applies a migration rendered from the given one, which gets the
+// duration of the run.
+func (c *Gloat) apply(ctx context.Context, migration, rendered *Migration) error {
+	err := up(ctx, c.Executor, rendered, c.Store)
+	migration.Duration = rendered.Duration
+
+	return err
 }
 
 // Revert rollbacks a migration.
 func (c *Gloat) Revert(migration *Migration) error {
-	return c.Executor.Down(migration, c.Store)
+	return c.RevertContext(context.Background(), migration)
+}
+
+// RevertContext rollbacks a migration. If the Executor supports it, the
+// context can cancel the migration while it runs.
+func (c *Gloat) RevertContext(ctx context.Context, migration *Migration) error {
+	if migration == nil {
+		return down(ctx, c.Executor, migration, c.Store)
+	}
+
+	rendered, err := migration.Render(c.Vars, Down)
+	if err != nil {
+		return err
+	}
+
+	return down(ctx, c.Executor, rendered, c.Store)
 }
 
 // SQLExecer is an interface compatible with sql.Tx.Exec. Can be passed as
@@ -72,6 +176,16 @@ type SQLExecer interface {
 	Query(query string, args ...interface{}) (*sql.Rows, error)
 }
 
+// ContextSQLExecer is an SQLExecer whose queries can be cancelled through a
+// context, like *sql.DB and *sql.Tx. The SQLExecers without it run their
+// queries without the context.
+type ContextSQLExecer interface {
+	SQLExecer
+
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
 // SQLTransactor is usually satisfied by *sql.DB, but can be used by wrappers
 // around it.
 type SQLTransactor interface {
@@ -79,3 +193,35 @@ type SQLTransactor interface {
 
 	Begin() (*sql.Tx, error)
 }
+
+// ContextSQLTransactor is an SQLTransactor that can begin transactions bound
+// to a context, like *sql.DB.
+type ContextSQLTransactor interface {
+	SQLTransactor
+
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+func execContext(ctx context.Context, execer SQLExecer, query string, args ...interface{}) (sql.Result, error) {
+	if execer, ok := execer.(ContextSQLExecer); ok {
+		return execer.ExecContext(ctx, query, args...)
+	}
+
+	return execer.Exec(query, args...)
+}
+
+func queryContext(ctx context.Context, execer SQLExecer, query string, args ...interface{}) (*sql.Rows, error) {
+	if execer, ok := execer.(ContextSQLExecer); ok {
+		return execer.QueryContext(ctx, query, args...)
+	}
+
+	return execer.Query(query, args...)
+}
+
+func beginTx(ctx context.Context, db SQLTransactor) (*sql.Tx, error) {
+	if db, ok := db.(ContextSQLTransactor); ok {
+		return db.BeginTx(ctx, nil)
+	}
+
+	return db.Begin()
+}
@@ -1,6 +1,7 @@
 package gloat
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"net/url"
@@ -72,14 +73,14 @@ func cleanState(fn func()) error {
 	return nil
 }
 
-func databaseStoreFactory(driver string, db *sql.DB) (Store, error) {
+func databaseStoreFactory(driver string, db *sql.DB, options ...StoreOption) (Store, error) {
 	switch driver {
 	case "postgres", "postgresql":
-		return NewPostgreSQLStore(db), nil
+		return NewPostgreSQLStore(db, options...), nil
 	case "mysql":
-		return NewMySQLStore(db), nil
+		return NewMySQLStore(db, options...), nil
 	case "sqlite", "sqlite3":
-		return NewMySQLStore(db), nil
+		return NewSQLite3Store(db, options...), nil
 	}
 
 	return nil, errors.New("unsupported database driver " + driver)
@@ -186,6 +187,33 @@ func TestRevert(t *testing.T) {
 	assert.True(t, called)
 }
 
+func TestApplyContext_FallsBackToExecutor(t *testing.T) {
+	called := false
+
+	gl.Store = &testingStore{}
+	gl.Executor = &stubbedExecutor{
+		up: func(*Migration, Store) error {
+			called = true
+			return nil
+		},
+	}
+
+	err := gl.ApplyContext(context.Background(), nil)
+	assert.Nil(t, err)
+
+	assert.True(t, called)
+}
+
+func TestUnappliedContext_Cancelled(t *testing.T) {
+	gl.Store = &testingStore{applied: Migrations{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := gl.UnappliedContext(ctx)
+	assert.Equal(t, context.Canceled, err)
+}
+
 func init() {
 	gl = Gloat{
 		Source:   NewFileSystemSource("testdata/migrations"),
@@ -1,5 +1,7 @@
 module github.com/gsamokovarov/gloat
 
+go 1.16
+
 require (
 	github.com/go-sql-driver/mysql v1.4.0
 	github.com/gsamokovarov/assert v0.0.0-20180414063448-8cd8ab63a335
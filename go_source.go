@@ -0,0 +1,66 @@
+package gloat
+
+import (
+	"context"
+	"fmt"
+)
+
+// MigrationFunc is a side of a migration written in Go. It gets the
+// transaction of the migration or the database itself, for migrations that do
+// not run in a transaction.
+type MigrationFunc func(ctx context.Context, tx SQLExecer) error
+
+// GoSource is a source of migrations written in Go. Combine it with the SQL
+// migrations of another source through NewMultiSource.
+//
+//	var goMigrations = gloat.NewGoSource()
+//
+//	func init() {
+//		goMigrations.Register(20180905150724, "backfill_user_tokens", backfillUp, backfillDown)
+//	}
+type GoSource struct {
+	migrations Migrations
+}
+
+// Register records a migration written in Go under a version. The down side
+// can be nil for irreversible migrations. Register panics if the version is
+// already taken or the up side is nil, as it is meant to be called from
+// init functions.
+func (s *GoSource) Register(version int64, name string, up, down MigrationFunc) {
+	s.RegisterWithOptions(version, name, DefaultMigrationOptions(), up, down)
+}
+
+// RegisterWithOptions records a migration written in Go with explicit
+// options, e.g. to run it outside of a transaction. See Register for the
+// details.
+func (s *GoSource) RegisterWithOptions(version int64, name string, options MigrationOptions, up, down MigrationFunc) {
+	if up == nil {
+		panic(fmt.Sprintf("gloat: Register migration %d with a nil up function", version))
+	}
+
+	if s.migrations.Has(version) {
+		panic(fmt.Sprintf("gloat: Register called twice for migration %d", version))
+	}
+
+	s.migrations = append(s.migrations, &Migration{
+		Path:     generateMigrationPath(version, name),
+		Version:  version,
+		Options:  options,
+		UpFunc:   up,
+		DownFunc: down,
+	})
+}
+
+// Collect returns the registered migrations.
+func (s *GoSource) Collect() (Migrations, error) {
+	migrations := make(Migrations, len(s.migrations))
+	copy(migrations, s.migrations)
+	migrations.Sort()
+
+	return migrations, nil
+}
+
+// NewGoSource creates a new source for migrations written in Go.
+func NewGoSource() *GoSource {
+	return &GoSource{}
+}
@@ -0,0 +1,112 @@
+package gloat
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func createUsers(ctx context.Context, tx SQLExecer) error {
+	_, err := tx.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY)`)
+	return err
+}
+
+func dropUsers(ctx context.Context, tx SQLExecer) error {
+	_, err := tx.Exec(`DROP TABLE users`)
+	return err
+}
+
+func TestGoSourceCollect(t *testing.T) {
+	source := NewGoSource()
+	source.Register(20180905150724, "create_users", createUsers, dropUsers)
+	source.Register(20170329154959, "irreversible", createUsers, nil)
+
+	migrations, err := source.Collect()
+	assert.Nil(t, err)
+
+	assert.Len(t, 2, migrations)
+
+	assert.Equal(t, 20170329154959, migrations[0].Version)
+	assert.False(t, migrations[0].Reversible())
+
+	assert.Equal(t, 20180905150724, migrations[1].Version)
+	assert.Equal(t, "create_users", migrations[1].Name())
+	assert.True(t, migrations[1].Reversible())
+	assert.True(t, migrations[1].Options.Transaction)
+}
+
+func TestGoSourceRegisterTwice(t *testing.T) {
+	source := NewGoSource()
+	source.Register(20180905150724, "create_users", createUsers, dropUsers)
+
+	defer func() {
+		assert.NotNil(t, recover())
+	}()
+
+	source.Register(20180905150724, "create_users_again", createUsers, dropUsers)
+}
+
+func TestMultiSourceCollect(t *testing.T) {
+	goSource := NewGoSource()
+	goSource.Register(20180101000000, "create_users", createUsers, dropUsers)
+
+	source := NewMultiSource(NewFileSystemSource("testdata/migrations"), goSource)
+
+	migrations, err := source.Collect()
+	assert.Nil(t, err)
+
+	assert.Len(t, 5, migrations)
+	assert.Equal(t, 20180101000000, migrations[2].Version)
+	assert.NotNil(t, migrations[2].UpFunc)
+}
+
+func TestMultiSourceCollectDuplicates(t *testing.T) {
+	goSource := NewGoSource()
+	goSource.Register(20170329154959, "create_users", createUsers, dropUsers)
+
+	source := NewMultiSource(NewFileSystemSource("testdata/migrations"), goSource)
+
+	_, err := source.Collect()
+	assert.Error(t, err)
+}
+
+func TestSQLExecutor_GoMigration(t *testing.T) {
+	goSource := NewGoSource()
+	goSource.Register(20180101000000, "create_users", createUsers, dropUsers)
+
+	migrations, err := goSource.Collect()
+	assert.Nil(t, err)
+
+	exe := NewSQLExecutor(db)
+
+	cleanState(func() {
+		err := exe.Up(migrations[0], new(testingStore))
+		assert.Nil(t, err)
+
+		_, err = db.Exec(`SELECT id FROM users LIMIT 1`)
+		assert.Nil(t, err)
+
+		err = exe.Down(migrations[0], new(testingStore))
+		assert.Nil(t, err)
+
+		_, err = db.Exec(`SELECT id FROM users LIMIT 1`)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestSQLExecutor_GoMigrationError(t *testing.T) {
+	broken := errors.New("broken")
+
+	goSource := NewGoSource()
+	goSource.Register(20180101000000, "broken", func(context.Context, SQLExecer) error { return broken }, nil)
+
+	migrations, err := goSource.Collect()
+	assert.Nil(t, err)
+
+	cleanState(func() {
+		err := NewSQLExecutor(db).Up(migrations[0], new(testingStore))
+		assert.True(t, errors.Is(err, broken))
+	})
+}
@@ -0,0 +1,234 @@
+// Package lint checks migrations for SQL that is dangerous to run against
+// production databases, like dropping tables or locking them for long.
+//
+//	linter := lint.New(gloat.PostgreSQL)
+//
+//	problems, err := linter.Lint(migrations)
+//	for _, problem := range problems {
+//		fmt.Println(problem)
+//	}
+//
+// Single migrations can opt out of rules by listing them in the lint_ignore
+// option of their options.json:
+//
+//	{"lint_ignore": ["drop-table"]}
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gsamokovarov/gloat"
+)
+
+var (
+	lineCommentRe  = regexp.MustCompile(`--[^\n]*`)
+	blockCommentRe = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	whitespaceRe   = regexp.MustCompile(`\s+`)
+)
+
+// Problem is a finding of a rule in a migration.
+type Problem struct {
+	// Rule is the name of the rule that found the problem.
+	Rule string
+
+	// Version is the version of the migration.
+	Version int64
+
+	// Path is the file of the problematic statement. It is the migration path
+	// for migrations without SQL, like the ones written in Go.
+	Path string
+
+	// Line is the line of the problematic statement in Path, or 0 if the
+	// problem is about the whole migration.
+	Line int
+
+	// Message describes the problem.
+	Message string
+}
+
+// String formats the problem like path:line: message (rule).
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s (%s)", p.Path, p.Message, p.Rule)
+	}
+
+	return fmt.Sprintf("%s:%d: %s (%s)", p.Path, p.Line, p.Message, p.Rule)
+}
+
+// Statement is an SQL statement of a migration, as seen by the rules.
+type Statement struct {
+	// SQL is the statement text without comments, in upper case and with its
+	// whitespace collapsed, so rules can match it with simple patterns.
+	SQL string
+
+	// Direction is the side of the migration the statement is in.
+	Direction gloat.Direction
+
+	// Line is the line the statement starts at, in the file of its side.
+	Line int
+}
+
+// Rule checks a migration for one kind of problem.
+type Rule struct {
+	// Name identifies the rule in the problems and the lint_ignore option.
+	Name string
+
+	// Check reports the problems of a migration, with its statements in both
+	// directions. The Rule, Version and Path of the problems are filled in
+	// by the Linter, if left blank.
+	Check func(migration *gloat.Migration, dialect gloat.Dialect, statements []Statement) []Problem
+}
+
+// Linter checks migrations against a set of rules.
+type Linter struct {
+	Dialect gloat.Dialect
+	Rules   []Rule
+}
+
+// Lint checks the migrations and returns their problems, ordered by version.
+// Migrations that cannot be split into statements fail the whole run.
+func (l *Linter) Lint(migrations gloat.Migrations) ([]Problem, error) {
+	var problems []Problem
+
+	for _, migration := range migrations {
+		statements, err := l.statements(migration)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", migration.Path, err)
+		}
+
+		ignored := make(map[string]bool)
+		for _, name := range migration.Options.LintIgnore {
+			ignored[name] = true
+		}
+
+		for _, rule := range l.Rules {
+			if ignored[rule.Name] {
+				continue
+			}
+
+			for _, problem := range rule.Check(migration, l.Dialect, statements) {
+				if problem.Rule == "" {
+					problem.Rule = rule.Name
+				}
+				if problem.Version == 0 {
+					problem.Version = migration.Version
+				}
+				if problem.Path == "" {
+					problem.Path = migration.Path
+				}
+
+				problems = append(problems, problem)
+			}
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Version < problems[j].Version
+	})
+
+	return problems, nil
+}
+
+func (l *Linter) statements(migration *gloat.Migration) ([]Statement, error) {
+	var statements []Statement
+
+	for _, side := range []struct {
+		direction gloat.Direction
+		content   []byte
+		offset    int
+	}{
+		{gloat.Up, migration.UpSQL, migration.UpLineOffset},
+		{gloat.Down, migration.DownSQL, migration.DownLineOffset},
+	} {
+		split, err := gloat.SplitStatements(side.content, l.Dialect)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, statement := range split {
+			sql := normalize(statement.SQL)
+			if sql == "" {
+				continue
+			}
+
+			// Point to the first line with code, after the leading comments.
+			codeOffset := statement.Offset + leadingCommentsLength(statement.SQL)
+
+			statements = append(statements, Statement{
+				SQL:       sql,
+				Direction: side.direction,
+				Line:      side.offset + bytes.Count(side.content[:codeOffset], []byte("\n")) + 1,
+			})
+		}
+	}
+
+	return statements, nil
+}
+
+// StatementProblem creates a problem pointing to a statement of a migration.
+func StatementProblem(migration *gloat.Migration, statement Statement, message string) Problem {
+	return Problem{
+		Path:    sectionPath(migration, statement.Direction),
+		Line:    statement.Line,
+		Message: message,
+	}
+}
+
+// sectionPath is the file a side of a migration is read from.
+func sectionPath(migration *gloat.Migration, direction gloat.Direction) string {
+	if strings.HasSuffix(migration.Path, gloat.MigrationFileExt) {
+		return migration.Path
+	}
+
+	return filepath.Join(migration.Path, string(direction)+".sql")
+}
+
+func normalize(sql string) string {
+	sql = blockCommentRe.ReplaceAllString(sql, " ")
+	sql = lineCommentRe.ReplaceAllString(sql, " ")
+	sql = whitespaceRe.ReplaceAllString(sql, " ")
+
+	return strings.ToUpper(strings.TrimSpace(sql))
+}
+
+func leadingCommentsLength(sql string) int {
+	i := 0
+
+	for i < len(sql) {
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(sql[i])):
+			i++
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				return len(sql)
+			}
+			i += end + 1
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i:], "*/")
+			if end < 0 {
+				return len(sql)
+			}
+			i += end + 2
+		default:
+			return i
+		}
+	}
+
+	return i
+}
+
+// New creates a Linter for a dialect. Without rules, it checks the
+// DefaultRules.
+func New(dialect gloat.Dialect, rules ...Rule) *Linter {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+
+	return &Linter{Dialect: dialect, Rules: rules}
+}
@@ -0,0 +1,121 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+	"github.com/gsamokovarov/gloat"
+)
+
+func TestLint_DropsAndMissingDown(t *testing.T) {
+	migrations := gloat.Migrations{
+		{
+			UpSQL:   []byte("-- Users are gone.\nDROP TABLE users;\n\nALTER TABLE posts DROP COLUMN user_id, DROP CONSTRAINT posts_user_id;\nALTER TABLE posts ALTER COLUMN title DROP NOT NULL;\n"),
+			Path:    "20170329154959_cleanup",
+			Version: 20170329154959,
+			Options: gloat.DefaultMigrationOptions(),
+		},
+	}
+
+	problems, err := New(gloat.SQLite3).Lint(migrations)
+	assert.Nil(t, err)
+
+	assert.Len(t, 3, problems)
+	assert.Equal(t, "20170329154959_cleanup/up.sql:2: DROP TABLE loses data and breaks the code still using the table (drop-table)", problems[0].String())
+	assert.Equal(t, "drop-column", problems[1].Rule)
+	assert.Equal(t, 4, problems[1].Line)
+	assert.Equal(t, "20170329154959_cleanup: the migration has no down side and cannot be reverted (missing-down)", problems[2].String())
+}
+
+func TestLint_PostgreSQLIndexes(t *testing.T) {
+	migrations := gloat.Migrations{
+		{
+			UpSQL:   []byte("CREATE INDEX users_email ON users (email);\nCREATE INDEX tags_name ON public.tags (name);\nCREATE INDEX CONCURRENTLY users_name ON users (name);\nREFRESH MATERIALIZED VIEW CONCURRENTLY stats;"),
+			DownSQL: []byte("DROP INDEX users_email;"),
+			Path:    "migrations/20180905150724_indexes.sql",
+			Version: 20180905150724,
+			Options: gloat.DefaultMigrationOptions(),
+		},
+	}
+
+	problems, err := New(gloat.PostgreSQL).Lint(migrations)
+	assert.Nil(t, err)
+
+	assert.Len(t, 3, problems)
+	assert.Equal(t, "create-index-non-concurrently", problems[0].Rule)
+	assert.Equal(t, 1, problems[0].Line)
+	assert.Equal(t, "create-index-non-concurrently", problems[1].Rule)
+	assert.Equal(t, "concurrently-in-transaction", problems[2].Rule)
+	assert.Equal(t, 3, problems[2].Line)
+
+	problems, err = New(gloat.PostgreSQL, CreateIndexNonConcurrently("users")).Lint(migrations)
+	assert.Nil(t, err)
+
+	assert.Len(t, 1, problems)
+	assert.Equal(t, "migrations/20180905150724_indexes.sql", problems[0].Path)
+}
+
+func TestLint_MySQLDDLInTransaction(t *testing.T) {
+	migrations := gloat.Migrations{
+		{
+			UpSQL:   []byte("INSERT INTO users (name) VALUES ('gloat');\nCREATE TABLE posts (id bigint);\nCREATE TABLE tags (id bigint);"),
+			DownSQL: []byte("DROP TABLE posts;"),
+			Path:    "20180905150724_posts",
+			Version: 20180905150724,
+			Options: gloat.DefaultMigrationOptions(),
+		},
+		{
+			UpSQL:   []byte("CREATE TABLE comments (id bigint);"),
+			DownSQL: []byte("DROP TABLE comments;"),
+			Path:    "20180905150725_comments",
+			Version: 20180905150725,
+			Options: gloat.MigrationOptions{Transaction: false, Split: true},
+		},
+	}
+
+	problems, err := New(gloat.MySQL, MySQLDDLInTransaction).Lint(migrations)
+	assert.Nil(t, err)
+
+	assert.Len(t, 1, problems)
+	assert.Equal(t, 20180905150724, problems[0].Version)
+	assert.Equal(t, 2, problems[0].Line)
+}
+
+func TestLint_Suppressions(t *testing.T) {
+	migrations := gloat.Migrations{
+		{
+			UpSQL:   []byte("DROP TABLE legacy_users;"),
+			Path:    "20170329154959_drop_legacy_users",
+			Version: 20170329154959,
+			Options: gloat.MigrationOptions{Transaction: true, Split: true, LintIgnore: []string{"drop-table", "missing-down"}},
+		},
+	}
+
+	problems, err := New(gloat.PostgreSQL).Lint(migrations)
+	assert.Nil(t, err)
+
+	assert.Len(t, 0, problems)
+}
+
+func TestLint_SingleFileLines(t *testing.T) {
+	content := []byte("-- gloat:up\nCREATE TABLE users (id bigint);\n\n-- gloat:down\nSELECT 1;\nDROP TABLE users;\n")
+
+	migration, err := gloat.MigrationFromFile("20170329154959_users.sql", func(string) ([]byte, error) { return content, nil })
+	assert.Nil(t, err)
+
+	problems, err := New(gloat.SQLite3, Rule{
+		Name: "no-down-drops",
+		Check: func(migration *gloat.Migration, dialect gloat.Dialect, statements []Statement) (problems []Problem) {
+			for _, statement := range statements {
+				if statement.Direction == gloat.Down && dropTableRe.MatchString(statement.SQL) {
+					problems = append(problems, StatementProblem(migration, statement, "dropped"))
+				}
+			}
+			return
+		},
+	}).Lint(gloat.Migrations{migration})
+	assert.Nil(t, err)
+
+	assert.Len(t, 1, problems)
+	assert.Equal(t, "20170329154959_users.sql:6: dropped (no-down-drops)", problems[0].String())
+}
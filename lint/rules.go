@@ -0,0 +1,177 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gsamokovarov/gloat"
+)
+
+var (
+	dropTableRe       = regexp.MustCompile(`^DROP TABLE\b`)
+	alterTableRe      = regexp.MustCompile(`^ALTER TABLE\b`)
+	alterDropRe       = regexp.MustCompile(`\bDROP (\w+)`)
+	createIndexRe     = regexp.MustCompile(`^CREATE (UNIQUE )?INDEX\b`)
+	indexTableRe      = regexp.MustCompile(`\bON (ONLY )?([\w."]+)`)
+	concurrentlyRe    = regexp.MustCompile(`\bCONCURRENTLY\b`)
+	refreshViewRe     = regexp.MustCompile(`^REFRESH MATERIALIZED VIEW\b`)
+	ddlRe             = regexp.MustCompile(`^(CREATE|ALTER|DROP|RENAME|TRUNCATE)\b`)
+	notColumnDropWord = map[string]bool{
+		"CONSTRAINT": true, "INDEX": true, "KEY": true, "PRIMARY": true,
+		"FOREIGN": true, "CHECK": true, "DEFAULT": true, "NOT": true,
+		"PARTITION": true, "EXPRESSION": true, "IDENTITY": true,
+	}
+)
+
+// DefaultRules returns every builtin rule, with the index rule applying to
+// every table.
+func DefaultRules() []Rule {
+	return []Rule{
+		DropTable,
+		DropColumn,
+		CreateIndexNonConcurrently(),
+		ConcurrentlyInTransaction,
+		MySQLDDLInTransaction,
+		MissingDown,
+	}
+}
+
+// DropTable flags tables dropped by up migrations. The data is gone for good,
+// and the code still running during a deploy may need the table.
+var DropTable = Rule{
+	Name: "drop-table",
+	Check: func(migration *gloat.Migration, dialect gloat.Dialect, statements []Statement) (problems []Problem) {
+		for _, statement := range statements {
+			if statement.Direction == gloat.Up && dropTableRe.MatchString(statement.SQL) {
+				problems = append(problems, StatementProblem(migration, statement, "DROP TABLE loses data and breaks the code still using the table"))
+			}
+		}
+
+		return
+	},
+}
+
+// DropColumn flags columns dropped by up migrations. Like with tables, the
+// data is gone and the running code may still select the column.
+var DropColumn = Rule{
+	Name: "drop-column",
+	Check: func(migration *gloat.Migration, dialect gloat.Dialect, statements []Statement) (problems []Problem) {
+		for _, statement := range statements {
+			if statement.Direction != gloat.Up || !alterTableRe.MatchString(statement.SQL) {
+				continue
+			}
+
+			for _, match := range alterDropRe.FindAllStringSubmatch(statement.SQL, -1) {
+				if !notColumnDropWord[match[1]] {
+					problems = append(problems, StatementProblem(migration, statement, "DROP COLUMN loses data and breaks the code still using the column"))
+					break
+				}
+			}
+		}
+
+		return
+	},
+}
+
+// CreateIndexNonConcurrently flags PostgreSQL indexes created without
+// CONCURRENTLY, which blocks the writes to the table until the index is
+// built. Given tables, only the indexes on them are flagged, so small tables
+// can be indexed the simple way.
+func CreateIndexNonConcurrently(tables ...string) Rule {
+	largeTables := make(map[string]bool)
+	for _, table := range tables {
+		largeTables[strings.ToUpper(table)] = true
+	}
+
+	return Rule{
+		Name: "create-index-non-concurrently",
+		Check: func(migration *gloat.Migration, dialect gloat.Dialect, statements []Statement) (problems []Problem) {
+			if dialect != gloat.PostgreSQL {
+				return
+			}
+
+			for _, statement := range statements {
+				if !createIndexRe.MatchString(statement.SQL) || concurrentlyRe.MatchString(statement.SQL) {
+					continue
+				}
+
+				if len(largeTables) > 0 && !largeTables[indexTable(statement.SQL)] {
+					continue
+				}
+
+				problems = append(problems, StatementProblem(migration, statement, "CREATE INDEX without CONCURRENTLY blocks the writes to the table"))
+			}
+
+			return
+		},
+	}
+}
+
+// ConcurrentlyInTransaction flags PostgreSQL statements running CONCURRENTLY
+// in a transactional migration, which PostgreSQL refuses. Turn the
+// transaction option off for them.
+var ConcurrentlyInTransaction = Rule{
+	Name: "concurrently-in-transaction",
+	Check: func(migration *gloat.Migration, dialect gloat.Dialect, statements []Statement) (problems []Problem) {
+		if dialect != gloat.PostgreSQL || !migration.Options.Transaction {
+			return
+		}
+
+		for _, statement := range statements {
+			// Refreshing materialized views concurrently is fine in a
+			// transaction.
+			if concurrentlyRe.MatchString(statement.SQL) && !refreshViewRe.MatchString(statement.SQL) {
+				problems = append(problems, StatementProblem(migration, statement, `CONCURRENTLY cannot run in a transaction, set "transaction": false`))
+			}
+		}
+
+		return
+	},
+}
+
+// MySQLDDLInTransaction flags MySQL migrations changing the schema in a
+// transaction. MySQL commits every schema change on its own, so a failing
+// migration is left half applied regardless of the transaction option. Only
+// the first schema change of a migration is reported.
+var MySQLDDLInTransaction = Rule{
+	Name: "mysql-ddl-in-transaction",
+	Check: func(migration *gloat.Migration, dialect gloat.Dialect, statements []Statement) []Problem {
+		if dialect != gloat.MySQL || !migration.Options.Transaction {
+			return nil
+		}
+
+		for _, statement := range statements {
+			if ddlRe.MatchString(statement.SQL) {
+				return []Problem{StatementProblem(migration, statement, fmt.Sprintf(`MySQL commits %s implicitly, the transaction does not protect it, set "transaction": false`, ddlRe.FindString(statement.SQL)))}
+			}
+		}
+
+		return nil
+	},
+}
+
+// MissingDown flags irreversible migrations, which have no down side.
+var MissingDown = Rule{
+	Name: "missing-down",
+	Check: func(migration *gloat.Migration, dialect gloat.Dialect, statements []Statement) []Problem {
+		if migration.Reversible() {
+			return nil
+		}
+
+		return []Problem{{Message: "the migration has no down side and cannot be reverted"}}
+	},
+}
+
+// indexTable extracts the table of a CREATE INDEX statement, without its
+// schema and quotes.
+func indexTable(sql string) string {
+	match := indexTableRe.FindStringSubmatch(sql)
+	if match == nil {
+		return ""
+	}
+
+	parts := strings.Split(match[2], ".")
+
+	return strings.Trim(parts[len(parts)-1], `"`)
+}
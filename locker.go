@@ -0,0 +1,344 @@
+package gloat
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"time"
+)
+
+// ErrLockTimeout is the error returned when a Locker cannot acquire its lock
+// before the context deadline.
+var ErrLockTimeout = errors.New("timed out waiting for the migration lock")
+
+// DefaultLockLease is the lease of the locks taken by a TableLocker. A lock
+// held by a crashed process expires after it.
+var DefaultLockLease = time.Minute
+
+// minLockLease is the shortest lease of a TableLocker lock. The lease is
+// renewed three times per lease, so shorter leases would flood the database.
+const minLockLease = time.Second
+
+// lockPollInterval is the time between two attempts to acquire a TableLocker
+// lock.
+var lockPollInterval = 250 * time.Millisecond
+
+// Locker guards a migration run against concurrent runs in other processes,
+// e.g. several application replicas migrating on boot.
+type Locker interface {
+	// Lock blocks until the lock is acquired or the context is done.
+	Lock(context.Context) error
+
+	// Unlock releases a lock acquired by Lock.
+	Unlock(context.Context) error
+}
+
+// PostgreSQLLocker is a Locker backed by a PostgreSQL session level advisory
+// lock. The lock is released automatically if the process dies.
+type PostgreSQLLocker struct {
+	db   *sql.DB
+	key  int64
+	conn *sql.Conn
+}
+
+// Lock acquires the advisory lock.
+func (l *PostgreSQLLocker) Lock(ctx context.Context) error {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, l.key); err != nil {
+		conn.Close()
+		return lockError(ctx, err)
+	}
+
+	l.conn = conn
+
+	return nil
+}
+
+// Unlock releases the advisory lock.
+func (l *PostgreSQLLocker) Unlock(ctx context.Context) error {
+	if l.conn == nil {
+		return nil
+	}
+
+	defer func() { l.conn = nil }()
+	defer l.conn.Close()
+
+	_, err := l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, l.key)
+	return err
+}
+
+// NewPostgreSQLLocker creates a Locker for PostgreSQL. The name of the lock,
+// usually the migrations table name, is hashed to an advisory lock key.
+func NewPostgreSQLLocker(db *sql.DB, name string) Locker {
+	hash := fnv.New64a()
+	hash.Write([]byte("gloat:" + name))
+
+	return &PostgreSQLLocker{db: db, key: int64(hash.Sum64())}
+}
+
+// MySQLLocker is a Locker backed by MySQL's GET_LOCK. The lock is released
+// automatically if the process dies.
+type MySQLLocker struct {
+	db   *sql.DB
+	name string
+	conn *sql.Conn
+}
+
+// Lock acquires the named lock. The wait is bounded by the context deadline.
+func (l *MySQLLocker) Lock(ctx context.Context) error {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	timeout := -1
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = int(math.Ceil(time.Until(deadline).Seconds()))
+	}
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, l.name, timeout).Scan(&acquired); err != nil {
+		conn.Close()
+		return lockError(ctx, err)
+	}
+
+	if acquired.Int64 != 1 {
+		conn.Close()
+		return ErrLockTimeout
+	}
+
+	l.conn = conn
+
+	return nil
+}
+
+// Unlock releases the named lock.
+func (l *MySQLLocker) Unlock(ctx context.Context) error {
+	if l.conn == nil {
+		return nil
+	}
+
+	defer func() { l.conn = nil }()
+	defer l.conn.Close()
+
+	_, err := l.conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, l.name)
+	return err
+}
+
+// NewMySQLLocker creates a Locker for MySQL. The name of the lock is usually
+// the migrations table name.
+func NewMySQLLocker(db *sql.DB, name string) Locker {
+	return &MySQLLocker{db: db, name: "gloat:" + name}
+}
+
+// TableLocker is a Locker for databases without advisory locks. It records the
+// lock holder in a table with a lease. While the lock is held, the lease is
+// renewed in the background, so a lock of a crashed process expires after at
+// most one lease.
+type TableLocker struct {
+	db    SQLTransactor
+	table string
+	lease time.Duration
+	owner string
+
+	createTableStatement string
+	expireLockStatement  string
+	insertLockStatement  string
+	selectLockStatement  string
+	renewLockStatement   string
+	deleteLockStatement  string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Lock acquires the lock, polling the lock table until the lock is free or its
+// lease has expired.
+func (l *TableLocker) Lock(ctx context.Context) error {
+	if _, err := execContext(ctx, l.db, l.createTableStatement); err != nil {
+		return err
+	}
+
+	for {
+		acquired, err := l.tryLock(ctx)
+		if err != nil {
+			return lockError(ctx, err)
+		}
+
+		if acquired {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return lockError(ctx, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+
+	go l.renew(l.stop, l.done)
+
+	return nil
+}
+
+// Unlock stops renewing the lease and releases the lock.
+func (l *TableLocker) Unlock(ctx context.Context) error {
+	if l.stop == nil {
+		return nil
+	}
+
+	close(l.stop)
+	<-l.done
+	l.stop, l.done = nil, nil
+
+	_, err := execContext(ctx, l.db, l.deleteLockStatement, l.owner)
+	return err
+}
+
+func (l *TableLocker) tryLock(ctx context.Context) (bool, error) {
+	now := time.Now()
+
+	if _, err := execContext(ctx, l.db, l.expireLockStatement, now.UnixNano()); err != nil {
+		return false, err
+	}
+
+	_, err := execContext(ctx, l.db, l.insertLockStatement, l.owner, now.Add(l.lease).UnixNano())
+	if err == nil {
+		return true, nil
+	}
+
+	// The insert fails on the primary key if somebody else holds the lock.
+	// Anything else is a genuine error.
+	rows, selectErr := queryContext(ctx, l.db, l.selectLockStatement)
+	if selectErr != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return false, err
+	}
+
+	return false, nil
+}
+
+func (l *TableLocker) renew(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(l.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			l.db.Exec(l.renewLockStatement, time.Now().Add(l.lease).UnixNano(), l.owner)
+		}
+	}
+}
+
+// NewTableLocker creates a Locker that records the lock in the given table,
+// quoted for the dialect. The table is automatically created if it does not
+// exist. The statements use ? placeholders, so it suits SQLite3, MySQL and
+// alike. A zero or negative lease means DefaultLockLease and leases shorter
+// than a second are raised to a second.
+func NewTableLocker(db SQLTransactor, dialect Dialect, table string, lease time.Duration) Locker {
+	switch {
+	case lease <= 0:
+		lease = DefaultLockLease
+	case lease < minLockLease:
+		lease = minLockLease
+	}
+
+	quotedTable := dialect.QuoteIdentifier(table)
+
+	return &TableLocker{
+		db:    db,
+		table: table,
+		lease: lease,
+		owner: lockOwner(),
+		createTableStatement: fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %s (
+				id INTEGER PRIMARY KEY NOT NULL,
+				owner VARCHAR(64) NOT NULL,
+				expires_at BIGINT NOT NULL
+			)`, quotedTable),
+		expireLockStatement: fmt.Sprintf(`
+			DELETE FROM %s
+			WHERE id=1 AND expires_at<?`, quotedTable),
+		insertLockStatement: fmt.Sprintf(`
+			INSERT INTO %s (id, owner, expires_at)
+			VALUES (1, ?, ?)`, quotedTable),
+		selectLockStatement: fmt.Sprintf(`
+			SELECT owner
+			FROM %s
+			WHERE id=1`, quotedTable),
+		renewLockStatement: fmt.Sprintf(`
+			UPDATE %s
+			SET expires_at=?
+			WHERE id=1 AND owner=?`, quotedTable),
+		deleteLockStatement: fmt.Sprintf(`
+			DELETE FROM %s
+			WHERE id=1 AND owner=?`, quotedTable),
+	}
+}
+
+// NewSQLite3Locker creates a Locker for SQLite3. The lock is recorded in a
+// table named after the lock with a _lock suffix.
+func NewSQLite3Locker(db SQLTransactor, name string) Locker {
+	return NewTableLocker(db, SQLite3, name+"_lock", DefaultLockLease)
+}
+
+func (c *Gloat) withLock(ctx context.Context, fn func() error) (err error) {
+	if c.Locker == nil {
+		return fn()
+	}
+
+	lockCtx := ctx
+	if c.LockTimeout > 0 {
+		var cancel context.CancelFunc
+
+		lockCtx, cancel = context.WithTimeout(ctx, c.LockTimeout)
+		defer cancel()
+	}
+
+	if err := c.Locker.Lock(lockCtx); err != nil {
+		return err
+	}
+
+	defer func() {
+		if unlockErr := c.Locker.Unlock(context.Background()); err == nil {
+			err = unlockErr
+		}
+	}()
+
+	return fn()
+}
+
+func lockError(ctx context.Context, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrLockTimeout
+	}
+
+	return err
+}
+
+func lockOwner() string {
+	token := make([]byte, 16)
+	rand.Read(token)
+
+	return hex.EncodeToString(token)
+}
@@ -0,0 +1,124 @@
+package gloat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gsamokovarov/assert"
+)
+
+type stubbedLocker struct {
+	calls []string
+	err   error
+}
+
+func (l *stubbedLocker) Lock(context.Context) error {
+	l.calls = append(l.calls, "lock")
+	return l.err
+}
+
+func (l *stubbedLocker) Unlock(context.Context) error {
+	l.calls = append(l.calls, "unlock")
+	return nil
+}
+
+func TestMigrateTo_HoldsTheLock(t *testing.T) {
+	locker := &stubbedLocker{}
+
+	gl.Store = &testingStore{applied: Migrations{}}
+	gl.Locker = locker
+	defer func() { gl.Locker = nil }()
+
+	_, err := gl.MigrateTo(Latest)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"lock", "unlock"}, locker.calls)
+}
+
+func TestSteps_DoesNotRunWithoutTheLock(t *testing.T) {
+	locker := &stubbedLocker{err: ErrLockTimeout}
+	called := false
+
+	gl.Store = &testingStore{applied: Migrations{}}
+	gl.Locker = locker
+	gl.Executor = &stubbedExecutor{
+		up: func(*Migration, Store) error {
+			called = true
+			return nil
+		},
+	}
+	defer func() {
+		gl.Locker = nil
+		gl.Executor = &testingExecutor{}
+	}()
+
+	_, err := gl.Steps(1)
+	assert.Equal(t, ErrLockTimeout, err)
+
+	assert.False(t, called)
+	assert.Equal(t, []string{"lock"}, locker.calls)
+}
+
+func TestTableLocker(t *testing.T) {
+	if dbDriver != "sqlite3" {
+		t.Skip("the table locker is used only for SQLite3")
+	}
+
+	defer db.Exec(`DROP TABLE IF EXISTS gloat_test_lock`)
+
+	first := NewTableLocker(db, SQLite3, "gloat_test_lock", time.Minute)
+	second := NewTableLocker(db, SQLite3, "gloat_test_lock", time.Minute)
+
+	assert.Nil(t, first.Lock(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, ErrLockTimeout, second.Lock(ctx))
+
+	assert.Nil(t, first.Unlock(context.Background()))
+	assert.Nil(t, second.Lock(context.Background()))
+	assert.Nil(t, second.Unlock(context.Background()))
+}
+
+func TestTableLocker_QuotedTable(t *testing.T) {
+	if dbDriver != "sqlite3" {
+		t.Skip("the table locker is used only for SQLite3")
+	}
+
+	defer db.Exec(`DROP TABLE IF EXISTS "my-migrations_lock"`)
+
+	locker := NewSQLite3Locker(db, "my-migrations")
+
+	assert.Nil(t, locker.Lock(context.Background()))
+	assert.Nil(t, locker.Unlock(context.Background()))
+}
+
+func TestTableLocker_ExpiredLease(t *testing.T) {
+	if dbDriver != "sqlite3" {
+		t.Skip("the table locker is used only for SQLite3")
+	}
+
+	defer db.Exec(`DROP TABLE IF EXISTS gloat_test_lock`)
+
+	locker := NewTableLocker(db, SQLite3, "gloat_test_lock", time.Minute)
+
+	_, err := db.Exec(locker.(*TableLocker).createTableStatement)
+	assert.Nil(t, err)
+
+	_, err = db.Exec(`INSERT INTO gloat_test_lock (id, owner, expires_at) VALUES (1, 'crashed', ?)`, time.Now().Add(-time.Second).UnixNano())
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.Nil(t, locker.Lock(ctx))
+	assert.Nil(t, locker.Unlock(context.Background()))
+}
+
+func TestNewTableLocker_Lease(t *testing.T) {
+	assert.Equal(t, DefaultLockLease, NewTableLocker(db, SQLite3, "gloat_test_lock", 0).(*TableLocker).lease)
+	assert.Equal(t, time.Second, NewTableLocker(db, SQLite3, "gloat_test_lock", time.Nanosecond).(*TableLocker).lease)
+	assert.Equal(t, 5*time.Second, NewTableLocker(db, SQLite3, "gloat_test_lock", 5*time.Second).(*TableLocker).lease)
+}
@@ -1,6 +1,7 @@
 package gloat
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"regexp"
@@ -11,9 +12,8 @@ import (
 )
 
 var (
-	now = time.Now().UTC()
-
 	nameNormalizerRe = regexp.MustCompile(`([a-z])([A-Z])`)
+	versionRe        = regexp.MustCompile(`^[0-9]+$`)
 	versionFormat    = "20060102150405"
 )
 
@@ -27,12 +27,78 @@ type Migration struct {
 	Path    string
 	Version int64
 	Options MigrationOptions
+
+	// UpFunc and DownFunc are the sides of migrations written in Go. If set,
+	// they are executed instead of the UpSQL and DownSQL content.
+	UpFunc   MigrationFunc
+	DownFunc MigrationFunc
+
+	// UpLineOffset and DownLineOffset are the number of lines before the
+	// UpSQL and DownSQL content in single-file migrations. They are used to
+	// report errors at the right lines of the file.
+	UpLineOffset   int
+	DownLineOffset int
+
+	// The following fields are filled only for migrations collected from
+	// stores that record them.
+
+	// AppliedAt is the time the migration was applied at.
+	AppliedAt time.Time
+
+	// Duration is the time it took for the migration to apply. The executor
+	// sets it right before the migration is inserted in the store.
+	Duration time.Duration
+
+	// Checksum is a hash of the UpSQL content at the time the migration was
+	// applied.
+	Checksum string
+
+	// GloatVersion is the version of gloat that applied the migration.
+	GloatVersion string
+
+	// Dirty is true for migrations that failed halfway outside of a
+	// transaction. See DirtyStore.
+	Dirty bool
 }
 
 // Reversible returns true if the migration DownSQL content is present. E.g. if
-// both of the directions are present in the migration folder.
+// both of the directions are present in the migration folder. Migrations
+// written in Go are reversible if they have a DownFunc.
 func (m *Migration) Reversible() bool {
-	return len(m.DownSQL) != 0
+	return len(m.DownSQL) != 0 || m.DownFunc != nil
+}
+
+// Name returns the name part of the migration path, without the version and
+// the extension of single-file migrations. E.g. introduce_domain_model for
+// 20170329154959_introduce_domain_model.
+func (m *Migration) Name() string {
+	if m.Path == "" {
+		return ""
+	}
+
+	parts := strings.SplitN(filepath.Base(m.Path), "_", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+
+	return strings.TrimSuffix(parts[1], MigrationFileExt)
+}
+
+// sectionPath returns the path of the file holding the SQL for the given
+// direction and the number of lines before the SQL in it.
+func (m *Migration) sectionPath(direction Direction) (string, int) {
+	switch {
+	case m.UpFunc != nil || m.DownFunc != nil:
+		return m.Path, 0
+	case isMigrationFile(m.Path) && direction == Down:
+		return m.Path, m.DownLineOffset
+	case isMigrationFile(m.Path):
+		return m.Path, m.UpLineOffset
+	case direction == Down:
+		return filepath.Join(m.Path, "down.sql"), 0
+	default:
+		return filepath.Join(m.Path, "up.sql"), 0
+	}
 }
 
 // Persistable is any migration with non blank Path.
@@ -41,16 +107,31 @@ func (m *Migration) Persistable() bool {
 }
 
 // GenerateMigration generates a new blank migration with blank UP and DOWN
-// content defined from user entered content.
+// content defined from user entered content. The version is a timestamp, that
+// grows with every generated migration.
 func GenerateMigration(str string) *Migration {
-	version := generateVersion()
-	path := generateMigrationPath(version, str)
+	migration, _ := GenerateMigrationWithVersion(defaultVersionGenerator, str)
+	return migration
+}
+
+// GenerateMigrationWithVersion generates a new blank migration, like
+// GenerateMigration, with a version from a VersionGenerator.
+func GenerateMigrationWithVersion(generator VersionGenerator, str string) (*Migration, error) {
+	version, err := generator.NextVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	formattedVersion := strconv.FormatInt(version, 10)
+	if formatter, ok := generator.(VersionFormatter); ok {
+		formattedVersion = formatter.FormatVersion(version)
+	}
 
 	return &Migration{
-		Path:    path,
+		Path:    migrationPath(formattedVersion, str),
 		Version: version,
 		Options: DefaultMigrationOptions(),
-	}
+	}, nil
 }
 
 // MigrationFromBytes builds a Migration struct from a path and a
@@ -92,22 +173,26 @@ func MigrationFromBytes(path string, read func(string) ([]byte, error)) (*Migrat
 }
 
 func generateMigrationPath(version int64, str string) string {
-	name := strings.ToLower(nameNormalizerRe.ReplaceAllString(str, "${1}_${2}"))
-	return fmt.Sprintf("%d_%s", version, name)
+	return migrationPath(strconv.FormatInt(version, 10), str)
 }
 
-func generateVersion() int64 {
-	version, _ := strconv.ParseInt(now.Format(versionFormat), 10, 64)
-	return version
+func migrationPath(version, str string) string {
+	name := strings.ToLower(nameNormalizerRe.ReplaceAllString(str, "${1}_${2}"))
+	return fmt.Sprintf("%s_%s", version, name)
 }
 
 func versionFromPath(path string) (int64, error) {
 	parts := strings.SplitN(filepath.Base(path), "_", 2)
-	if len(parts) == 0 {
-		return 0, fmt.Errorf("cannot extract version from %s", path)
+	if !versionRe.MatchString(parts[0]) {
+		return 0, InvalidVersionError{path}
+	}
+
+	version, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, InvalidVersionError{path}
 	}
 
-	return strconv.ParseInt(parts[0], 10, 64)
+	return version, nil
 }
 
 // Migrations is a slice of Migration pointers.
@@ -136,6 +221,23 @@ func (m Migrations) Except(migrations Migrations) (excepted Migrations) {
 	return
 }
 
+// Find returns the migration with the given version. Can be nil, if there is
+// no such migration.
+func (m Migrations) Find(version int64) *Migration {
+	for _, migration := range m {
+		if migration.Version == version {
+			return migration
+		}
+	}
+
+	return nil
+}
+
+// Has reports whether a migration with the given version is present.
+func (m Migrations) Has(version int64) bool {
+	return m.Find(version) != nil
+}
+
 // Implementation for the sort.Sort interface.
 
 func (m Migrations) Len() int           { return len(m) }
@@ -160,12 +262,18 @@ func (m Migrations) Current() *Migration {
 // UnappliedMigrations selects the unapplied migrations from a Source. For a
 // migration to be unapplied it should not be present in the Store.
 func UnappliedMigrations(store, source Source) (Migrations, error) {
-	appliedMigrations, err := store.Collect()
+	return UnappliedMigrationsContext(context.Background(), store, source)
+}
+
+// UnappliedMigrationsContext selects the unapplied migrations from a Source.
+// See UnappliedMigrations for the details.
+func UnappliedMigrationsContext(ctx context.Context, store, source Source) (Migrations, error) {
+	appliedMigrations, err := collect(ctx, store)
 	if err != nil {
 		return nil, err
 	}
 
-	incomingMigrations, err := source.Collect()
+	incomingMigrations, err := collect(ctx, source)
 	if err != nil {
 		return nil, err
 	}
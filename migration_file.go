@@ -0,0 +1,113 @@
+package gloat
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MigrationFileExt is the extension of the single-file migrations.
+const MigrationFileExt = ".sql"
+
+var migrationDirectiveRe = regexp.MustCompile(`^--\s*gloat:(\w+)\s*(.*)$`)
+
+// MigrationFromFile builds a Migration struct out of a single-file migration.
+// Such migrations keep both of their sides in one file, split by marker
+// comments:
+//
+//	-- gloat:options {"transaction": false}
+//
+//	-- gloat:up
+//	CREATE INDEX CONCURRENTLY users_email_idx ON users (email);
+//
+//	-- gloat:down
+//	DROP INDEX users_email_idx;
+//
+// The down section and the options are optional. The options take the same
+// JSON as options.json in the migration folders.
+func MigrationFromFile(path string, read func(string) ([]byte, error)) (*Migration, error) {
+	version, err := versionFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	upSQL, downSQL, optionsJSON, upLineOffset, downLineOffset, err := splitMigrationFile(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	options, err := parseMigrationOptions(optionsJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return &Migration{
+		UpSQL:          upSQL,
+		DownSQL:        downSQL,
+		Path:           path,
+		Version:        version,
+		Options:        options,
+		UpLineOffset:   upLineOffset,
+		DownLineOffset: downLineOffset,
+	}, nil
+}
+
+func splitMigrationFile(content []byte) (upSQL, downSQL, optionsJSON []byte, upLineOffset, downLineOffset int, err error) {
+	var section *[]byte
+
+	seen := make(map[string]bool)
+
+	for lineno, line := range bytes.SplitAfter(content, []byte("\n")) {
+		match := migrationDirectiveRe.FindSubmatch(bytes.TrimSpace(line))
+		if match == nil {
+			if section != nil {
+				*section = append(*section, line...)
+			} else if len(bytes.TrimSpace(line)) != 0 && !bytes.HasPrefix(bytes.TrimSpace(line), []byte("--")) {
+				return nil, nil, nil, 0, 0, fmt.Errorf("SQL found before the -- gloat:up marker")
+			}
+
+			continue
+		}
+
+		directive := string(match[1])
+		if seen[directive] {
+			return nil, nil, nil, 0, 0, fmt.Errorf("duplicate -- gloat:%s directive", directive)
+		}
+		seen[directive] = true
+
+		switch directive {
+		case "up":
+			upSQL = []byte{}
+			upLineOffset = lineno + 1
+			section = &upSQL
+		case "down":
+			downSQL = []byte{}
+			downLineOffset = lineno + 1
+			section = &downSQL
+		case "options":
+			optionsJSON = match[2]
+		default:
+			return nil, nil, nil, 0, 0, fmt.Errorf("unknown -- gloat:%s directive", directive)
+		}
+	}
+
+	if upSQL == nil {
+		return nil, nil, nil, 0, 0, fmt.Errorf("missing the -- gloat:up marker")
+	}
+
+	if len(bytes.TrimSpace(downSQL)) == 0 {
+		downSQL = nil
+	}
+
+	return
+}
+
+func isMigrationFile(name string) bool {
+	return strings.HasSuffix(name, MigrationFileExt)
+}
@@ -0,0 +1,48 @@
+package gloat
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func TestMigrationFromFile(t *testing.T) {
+	expectedPath := "testdata/mixed_migrations/20180905150724_add_users_token.sql"
+
+	m, err := MigrationFromFile(expectedPath, ioutil.ReadFile)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 20180905150724, m.Version)
+	assert.Equal(t, expectedPath, m.Path)
+	assert.Equal(t, "add_users_token", m.Name())
+	assert.Equal(t, "ALTER TABLE users ADD COLUMN token character varying;\n\n", string(m.UpSQL))
+	assert.Equal(t, "ALTER TABLE users DROP COLUMN token;\n", string(m.DownSQL))
+	assert.False(t, m.Options.Transaction)
+}
+
+func TestMigrationFromFile_Irreversible(t *testing.T) {
+	read := func(string) ([]byte, error) {
+		return []byte("-- gloat:up\nCREATE TABLE users ();\n-- gloat:down\n\n"), nil
+	}
+
+	m, err := MigrationFromFile("20180905150724_add_users.sql", read)
+	assert.Nil(t, err)
+
+	assert.False(t, m.Reversible())
+	assert.True(t, m.Options.Transaction)
+}
+
+func TestMigrationFromFile_Malformed(t *testing.T) {
+	for _, content := range []string{
+		"CREATE TABLE users ();",
+		"CREATE TABLE users ();\n-- gloat:up\n",
+		"-- gloat:up\n-- gloat:up\n",
+		"-- gloat:sideways\n-- gloat:up\n",
+	} {
+		read := func(string) ([]byte, error) { return []byte(content), nil }
+
+		_, err := MigrationFromFile("20180905150724_add_users.sql", read)
+		assert.Error(t, err)
+	}
+}
@@ -9,6 +9,19 @@ import (
 // options (transaction) are not supported by every RDBMS (ahem, MySQL).
 type MigrationOptions struct {
 	Transaction bool `json:"transaction"`
+
+	// Split runs the migration statement by statement. Turn it off to send
+	// the whole migration to the database in one go, like older gloat
+	// versions did.
+	Split bool `json:"split"`
+
+	// LintIgnore lists the rules of the lint package that are not checked
+	// for the migration, like drop-table for a table that is meant to go.
+	LintIgnore []string `json:"lint_ignore,omitempty"`
+
+	// Template renders the SQL of the migration as a text/template template
+	// with the Vars of Gloat, before it is executed. See Migration.Render.
+	Template bool `json:"template,omitempty"`
 }
 
 // DefaultMigrationOptions generate the default migration options.
@@ -18,14 +31,44 @@ type MigrationOptions struct {
 func DefaultMigrationOptions() MigrationOptions {
 	return MigrationOptions{
 		Transaction: true,
+		Split:       true,
 	}
 }
 
+// Equal reports whether the options are the same as other ones.
+func (o MigrationOptions) Equal(other MigrationOptions) bool {
+	if o.Transaction != other.Transaction || o.Split != other.Split || o.Template != other.Template {
+		return false
+	}
+
+	if len(o.LintIgnore) != len(other.LintIgnore) {
+		return false
+	}
+
+	for i := range o.LintIgnore {
+		if o.LintIgnore[i] != other.LintIgnore[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsDefault reports whether the options are the DefaultMigrationOptions.
+func (o MigrationOptions) IsDefault() bool {
+	return o.Equal(DefaultMigrationOptions())
+}
+
+// parseMigrationOptions decodes the options of a migration. Migrations
+// without options get the defaults. Split was added later on, so it stays on
+// for options that do not mention it, while a missing transaction means no
+// transaction, as it always did.
 func parseMigrationOptions(data []byte) (options MigrationOptions, err error) {
 	if data == nil {
 		return DefaultMigrationOptions(), nil
 	}
 
+	options = MigrationOptions{Split: true}
 	err = json.NewDecoder(bytes.NewReader(data)).Decode(&options)
 
 	return
@@ -1,6 +1,7 @@
 package gloat
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"testing"
 
@@ -11,6 +12,7 @@ func TestDefaultMigrationOptions(t *testing.T) {
 	options := DefaultMigrationOptions()
 
 	assert.True(t, options.Transaction)
+	assert.True(t, options.Split)
 }
 
 func TestMigrationWithoutExplicitOptions(t *testing.T) {
@@ -30,3 +32,43 @@ func TestMigrationWithExplicitOptions(t *testing.T) {
 
 	assert.False(t, m.Options.Transaction)
 }
+
+func TestMigrationOptionsKeepSplit(t *testing.T) {
+	options, err := parseMigrationOptions([]byte(`{"transaction": true}`))
+	assert.Nil(t, err)
+
+	assert.True(t, options.Transaction)
+	assert.True(t, options.Split)
+
+	options, err = parseMigrationOptions([]byte(`{"split": false}`))
+	assert.Nil(t, err)
+
+	assert.False(t, options.Split)
+}
+
+func TestMigrationOptionsEmpty(t *testing.T) {
+	options, err := parseMigrationOptions([]byte(`{}`))
+	assert.Nil(t, err)
+
+	assert.False(t, options.Transaction)
+	assert.True(t, options.Split)
+}
+
+func TestMigrationOptionsLintIgnore(t *testing.T) {
+	options, err := parseMigrationOptions([]byte(`{"transaction": true, "lint_ignore": ["drop-table", "missing-down"]}`))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"drop-table", "missing-down"}, options.LintIgnore)
+	assert.False(t, options.IsDefault())
+	assert.True(t, options.Equal(MigrationOptions{Transaction: true, Split: true, LintIgnore: []string{"drop-table", "missing-down"}}))
+	assert.False(t, options.Equal(MigrationOptions{Transaction: true, Split: true, LintIgnore: []string{"drop-table"}}))
+
+	data, err := json.Marshal(options)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"transaction":true,"split":true,"lint_ignore":["drop-table","missing-down"]}`, string(data))
+}
+
+func TestMigrationOptionsIsDefault(t *testing.T) {
+	assert.True(t, DefaultMigrationOptions().IsDefault())
+	assert.False(t, MigrationOptions{Transaction: true, Split: true, Template: true}.IsDefault())
+}
@@ -0,0 +1,81 @@
+package gloat
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"text/template"
+)
+
+// Vars are the variables templated migrations are rendered with, like the
+// schema or the role names that differ between deployments.
+type Vars map[string]string
+
+// templateFuncs are the functions available in templated migrations.
+var templateFuncs = template.FuncMap{
+	// env reads an environment variable, failing if it is not set, so a
+	// typo does not end up as an empty identifier in the SQL.
+	"env": func(name string) (string, error) {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+
+		return value, nil
+	},
+}
+
+// Render returns a copy of the migration with the SQL of one direction
+// rendered as a text/template template, if the Template option is set. Only
+// that side is rendered, so the variables of the other side are not needed.
+// The variables are available as {{.name}} and the environment through
+// {{env "NAME"}}. Missing variables are an error. Migrations without the
+// Template option are returned as they are, so SQL containing {{ keeps
+// working.
+//
+// The checksum of a migration is taken from its SQL before the rendering, so
+// the same migration deployed with different variables does not drift.
+func (m *Migration) Render(vars Vars, direction Direction) (*Migration, error) {
+	if !m.Options.Template {
+		return m, nil
+	}
+
+	rendered := *m
+
+	var err error
+	switch direction {
+	case Up:
+		rendered.UpSQL, err = renderSQL(m, Up, m.UpSQL, vars)
+	case Down:
+		rendered.DownSQL, err = renderSQL(m, Down, m.DownSQL, vars)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &rendered, nil
+}
+
+func renderSQL(migration *Migration, direction Direction, content []byte, vars Vars) ([]byte, error) {
+	if content == nil {
+		return nil, nil
+	}
+
+	path, _ := migration.sectionPath(direction)
+
+	tmpl, err := template.New(path).Funcs(templateFuncs).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	if vars == nil {
+		vars = Vars{}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,137 @@
+package gloat
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func TestMigrationRender(t *testing.T) {
+	migration := &Migration{
+		UpSQL:   []byte(`CREATE SCHEMA {{.schema}}; GRANT USAGE ON SCHEMA {{.schema}} TO {{env "GLOAT_TEST_ROLE"}};`),
+		DownSQL: []byte(`DROP SCHEMA {{.schema}};`),
+		Path:    "20170329154959_create_schema",
+		Version: 20170329154959,
+		Options: MigrationOptions{Transaction: true, Split: true, Template: true},
+	}
+
+	os.Setenv("GLOAT_TEST_ROLE", "reader")
+	defer os.Unsetenv("GLOAT_TEST_ROLE")
+
+	rendered, err := migration.Render(Vars{"schema": "tenant"}, Up)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "CREATE SCHEMA tenant; GRANT USAGE ON SCHEMA tenant TO reader;", string(rendered.UpSQL))
+	assert.Equal(t, `DROP SCHEMA {{.schema}};`, string(rendered.DownSQL))
+
+	rendered, err = migration.Render(Vars{"schema": "tenant"}, Down)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "DROP SCHEMA tenant;", string(rendered.DownSQL))
+	assert.Equal(t, `DROP SCHEMA {{.schema}};`, string(migration.DownSQL))
+
+	_, err = migration.Render(Vars{}, Up)
+	assert.Error(t, err)
+
+	os.Unsetenv("GLOAT_TEST_ROLE")
+
+	_, err = migration.Render(Vars{"schema": "tenant"}, Up)
+	assert.Error(t, err)
+}
+
+func TestMigrationRender_OtherSide(t *testing.T) {
+	migration := &Migration{
+		UpSQL:   []byte(`CREATE SCHEMA {{.schema}};`),
+		DownSQL: []byte(`ALTER SCHEMA {{.schema}} RENAME TO {{.old_schema}};`),
+		Path:    "20170329154959_create_schema",
+		Version: 20170329154959,
+		Options: MigrationOptions{Transaction: true, Split: true, Template: true},
+	}
+
+	rendered, err := migration.Render(Vars{"schema": "x"}, Up)
+	assert.Nil(t, err)
+	assert.Equal(t, "CREATE SCHEMA x;", string(rendered.UpSQL))
+
+	_, err = migration.Render(Vars{"schema": "x"}, Down)
+	assert.Error(t, err)
+}
+
+func TestMigrationRender_NotTemplated(t *testing.T) {
+	migration := &Migration{
+		UpSQL:   []byte(`SELECT '{{ not a template }}';`),
+		Path:    "20170329154959_braces",
+		Version: 20170329154959,
+		Options: DefaultMigrationOptions(),
+	}
+
+	rendered, err := migration.Render(Vars{}, Up)
+	assert.Nil(t, err)
+	assert.Equal(t, migration, rendered)
+}
+
+func TestApply_RendersTemplates(t *testing.T) {
+	migration := &Migration{
+		UpSQL:   []byte(`CREATE SCHEMA {{.schema}};`),
+		Path:    "20170329154959_create_schema",
+		Version: 20170329154959,
+		Options: MigrationOptions{Transaction: true, Split: true, Template: true},
+	}
+
+	var executed *Migration
+
+	gl.Store = &testingStore{}
+	gl.Executor = &stubbedExecutor{
+		up: func(m *Migration, _ Store) error {
+			executed = m
+			return nil
+		},
+	}
+	gl.Vars = Vars{"schema": "tenant"}
+	defer func() { gl.Vars = nil }()
+
+	assert.Nil(t, gl.Apply(migration))
+
+	assert.Equal(t, "CREATE SCHEMA tenant;", string(executed.UpSQL))
+	assert.Equal(t, checksum([]byte(`CREATE SCHEMA {{.schema}};`)), executed.Checksum)
+	assert.Equal(t, `CREATE SCHEMA {{.schema}};`, string(migration.UpSQL))
+}
+
+func TestMigrateTo_RendersBeforeRunning(t *testing.T) {
+	migrations := Migrations{
+		&Migration{
+			UpSQL:   []byte(`CREATE SCHEMA {{.schema}};`),
+			Path:    "20170329154959_create_schema",
+			Version: 20170329154959,
+			Options: MigrationOptions{Transaction: true, Split: true, Template: true},
+		},
+		&Migration{
+			UpSQL:   []byte(`GRANT USAGE ON SCHEMA {{.schema}} TO {{.role}};`),
+			Path:    "20180905150724_grant_usage",
+			Version: 20180905150724,
+			Options: MigrationOptions{Transaction: true, Split: true, Template: true},
+		},
+	}
+
+	var executed Migrations
+
+	prevSource := gl.Source
+	defer func() { gl.Source = prevSource }()
+
+	gl.Source = &testingStore{applied: migrations}
+	gl.Store = &testingStore{}
+	gl.Executor = &stubbedExecutor{
+		up: func(m *Migration, _ Store) error {
+			executed = append(executed, m)
+			return nil
+		},
+	}
+	gl.Vars = Vars{"schema": "tenant"}
+	defer func() { gl.Vars = nil }()
+
+	plan, err := gl.MigrateTo(Latest)
+	assert.Error(t, err)
+
+	assert.Len(t, 0, plan)
+	assert.Len(t, 0, executed)
+}
@@ -54,3 +54,11 @@ func TestMigrationsExcept(t *testing.T) {
 	exceptedMigrations = migrations.Except(Migrations{m})
 	assert.Len(t, 0, exceptedMigrations)
 }
+
+func TestMigrationName(t *testing.T) {
+	m := Migration{Path: "testdata/migrations/20170329154959_introduce_domain_model"}
+	assert.Equal(t, "introduce_domain_model", m.Name())
+
+	m = Migration{}
+	assert.Equal(t, "", m.Name())
+}
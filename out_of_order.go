@@ -0,0 +1,92 @@
+package gloat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OutOfOrderPolicy decides what happens to unapplied migrations that are older
+// than the latest applied one. They usually come from long-lived branches
+// merged after newer migrations were already applied.
+type OutOfOrderPolicy string
+
+// The out-of-order policies.
+const (
+	// RejectOutOfOrder fails with an OutOfOrderError. It is the default.
+	RejectOutOfOrder OutOfOrderPolicy = ""
+
+	// WarnOutOfOrder reports the out-of-order migrations through the
+	// Logger and applies them.
+	WarnOutOfOrder OutOfOrderPolicy = "warn"
+
+	// AllowOutOfOrder applies the out-of-order migrations silently.
+	AllowOutOfOrder OutOfOrderPolicy = "allow"
+)
+
+// OutOfOrderError is the error returned for unapplied migrations older than
+// the latest applied migration.
+type OutOfOrderError struct {
+	// Versions are the out-of-order migration versions, oldest first.
+	Versions []int64
+
+	// Latest is the version of the latest applied migration.
+	Latest int64
+}
+
+// Error implements the error interface.
+func (err OutOfOrderError) Error() string {
+	versions := make([]string, len(err.Versions))
+	for i, version := range err.Versions {
+		versions[i] = fmt.Sprint(version)
+	}
+
+	return fmt.Sprintf(
+		"migrations out of order: %s not applied, but older than the latest applied migration %d",
+		strings.Join(versions, ", "),
+		err.Latest,
+	)
+}
+
+// outOfOrder returns an OutOfOrderError if any of the unapplied migrations is
+// older than the latest of the applied migrations up to the given version.
+// The unapplied migrations have to be sorted.
+func outOfOrder(appliedMigrations, unappliedMigrations Migrations, version int64) error {
+	var latest int64
+	for _, migration := range appliedMigrations {
+		if migration.Version <= version && migration.Version > latest {
+			latest = migration.Version
+		}
+	}
+
+	var versions []int64
+	for _, migration := range unappliedMigrations {
+		if migration.Version < latest {
+			versions = append(versions, migration.Version)
+		}
+	}
+
+	if len(versions) == 0 {
+		return nil
+	}
+
+	return OutOfOrderError{Versions: versions, Latest: latest}
+}
+
+// checkOutOfOrder applies the OutOfOrder policy to the migrations about to be
+// applied. See outOfOrder for the arguments.
+func (c *Gloat) checkOutOfOrder(appliedMigrations, unappliedMigrations Migrations, version int64) error {
+	err := outOfOrder(appliedMigrations, unappliedMigrations, version)
+	if err == nil {
+		return nil
+	}
+
+	switch c.OutOfOrder {
+	case WarnOutOfOrder:
+		c.logf("Warning: %v", err)
+		return nil
+	case AllowOutOfOrder:
+		return nil
+	default:
+		return err
+	}
+}
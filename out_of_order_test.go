@@ -0,0 +1,71 @@
+package gloat
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func TestUnapplied_OutOfOrder(t *testing.T) {
+	gl.Store = &testingStore{
+		applied: Migrations{
+			&Migration{Version: 20170329154959},
+			&Migration{Version: 20180905150724},
+		},
+	}
+
+	_, err := gl.Unapplied()
+	assert.Equal(t, OutOfOrderError{Versions: []int64{20170511172647}, Latest: 20180905150724}, err)
+	assert.Equal(t, "migrations out of order: 20170511172647 not applied, but older than the latest applied migration 20180905150724", err.Error())
+}
+
+func TestUnapplied_OutOfOrderWarn(t *testing.T) {
+	var out bytes.Buffer
+
+	gl.Store = &testingStore{
+		applied: Migrations{
+			&Migration{Version: 20170329154959},
+			&Migration{Version: 20180905150724},
+		},
+	}
+	gl.OutOfOrder = WarnOutOfOrder
+	gl.Logger = log.New(&out, "", 0)
+	defer func() { gl.OutOfOrder, gl.Logger = RejectOutOfOrder, nil }()
+
+	migrations, err := gl.Unapplied()
+	assert.Nil(t, err)
+	assert.Len(t, 2, migrations)
+
+	assert.Equal(t, "Warning: migrations out of order: 20170511172647 not applied, but older than the latest applied migration 20180905150724\n", out.String())
+}
+
+func TestUnapplied_OutOfOrderAllow(t *testing.T) {
+	gl.Store = &testingStore{
+		applied: Migrations{
+			&Migration{Version: 20180905150724},
+		},
+	}
+	gl.OutOfOrder = AllowOutOfOrder
+	defer func() { gl.OutOfOrder = RejectOutOfOrder }()
+
+	migrations, err := gl.Unapplied()
+	assert.Nil(t, err)
+	assert.Len(t, 3, migrations)
+}
+
+func TestPlanTo_OutOfOrderRejected(t *testing.T) {
+	gl.Store = &testingStore{
+		applied: Migrations{
+			&Migration{Version: 20170329154959},
+			&Migration{Version: 20180905150724},
+		},
+	}
+
+	_, err := gl.PlanTo(Latest)
+	assert.Equal(t, OutOfOrderError{Versions: []int64{20170511172647}, Latest: 20180905150724}, err)
+
+	_, err = gl.PlanSteps(1)
+	assert.Equal(t, OutOfOrderError{Versions: []int64{20170511172647}, Latest: 20180905150724}, err)
+}
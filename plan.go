@@ -0,0 +1,261 @@
+package gloat
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Latest is a version that is greater than every other version. Migrating to
+// it applies all of the unapplied migrations.
+const Latest int64 = math.MaxInt64
+
+// Direction is the direction a migration is executed in.
+type Direction string
+
+// The directions a migration can be executed in.
+const (
+	Up   Direction = "up"
+	Down Direction = "down"
+)
+
+// Step is a migration to be applied or reverted.
+type Step struct {
+	Migration *Migration
+	Direction Direction
+}
+
+// Plan is an ordered list of steps that bring a store to a wanted version.
+type Plan []Step
+
+// Logger reports the progress of migration runs. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// PlanTo works out the steps needed to reach a version. The migrations applied
+// after the version are reverted newest first, then the unapplied migrations up
+// to and including the version are applied oldest first. Unapplied migrations
+// older than the latest applied one are subject to the OutOfOrder policy. A
+// dirty migration in the store fails with a DirtyError.
+func (c *Gloat) PlanTo(version int64) (Plan, error) {
+	return c.PlanToContext(context.Background(), version)
+}
+
+// PlanToContext works out the steps needed to reach a version. See PlanTo for
+// the details.
+func (c *Gloat) PlanToContext(ctx context.Context, version int64) (Plan, error) {
+	appliedMigrations, availableMigrations, err := c.collectBoth(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dirty(appliedMigrations); err != nil {
+		return nil, err
+	}
+
+	if version != 0 && version != Latest && !appliedMigrations.Has(version) && !availableMigrations.Has(version) {
+		return nil, fmt.Errorf("cannot migrate to unknown version %d", version)
+	}
+
+	var plan Plan
+
+	appliedMigrations.Sort()
+	for i := len(appliedMigrations) - 1; i >= 0; i-- {
+		if appliedMigrations[i].Version <= version {
+			break
+		}
+
+		step, err := revertStep(appliedMigrations[i].Version, availableMigrations)
+		if err != nil {
+			return nil, err
+		}
+
+		plan = append(plan, step)
+	}
+
+	unappliedMigrations := appliedMigrations.Except(availableMigrations)
+	unappliedMigrations.Sort()
+
+	var upMigrations Migrations
+	for _, migration := range unappliedMigrations {
+		if migration.Version <= version {
+			upMigrations = append(upMigrations, migration)
+			plan = append(plan, Step{Migration: migration, Direction: Up})
+		}
+	}
+
+	if err := c.checkOutOfOrder(appliedMigrations, upMigrations, version); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// PlanSteps works out the steps needed to apply the next n unapplied
+// migrations. If n is negative, the last -n applied migrations are reverted
+// instead. Reverting with no applied migrations returns ErrNoCurrentMigration.
+// Unapplied migrations older than the latest applied one are subject to the
+// OutOfOrder policy. A dirty migration in the store fails with a DirtyError.
+func (c *Gloat) PlanSteps(n int) (Plan, error) {
+	return c.PlanStepsContext(context.Background(), n)
+}
+
+// PlanStepsContext works out the steps needed to apply or revert n
+// migrations. See PlanSteps for the details.
+func (c *Gloat) PlanStepsContext(ctx context.Context, n int) (Plan, error) {
+	appliedMigrations, availableMigrations, err := c.collectBoth(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dirty(appliedMigrations); err != nil {
+		return nil, err
+	}
+
+	var plan Plan
+
+	if n >= 0 {
+		unappliedMigrations := appliedMigrations.Except(availableMigrations)
+		unappliedMigrations.Sort()
+
+		if len(unappliedMigrations) > n {
+			unappliedMigrations = unappliedMigrations[:n]
+		}
+
+		if err := c.checkOutOfOrder(appliedMigrations, unappliedMigrations, Latest); err != nil {
+			return nil, err
+		}
+
+		for _, migration := range unappliedMigrations {
+			plan = append(plan, Step{Migration: migration, Direction: Up})
+		}
+
+		return plan, nil
+	}
+
+	if len(appliedMigrations) == 0 {
+		return nil, ErrNoCurrentMigration
+	}
+
+	appliedMigrations.Sort()
+	for i := len(appliedMigrations) - 1; i >= 0 && len(plan) < -n; i-- {
+		step, err := revertStep(appliedMigrations[i].Version, availableMigrations)
+		if err != nil {
+			return nil, err
+		}
+
+		plan = append(plan, step)
+	}
+
+	return plan, nil
+}
+
+// MigrateTo applies or reverts migrations until the store reaches a version.
+// Use Latest to apply every unapplied migration. The executed steps are
+// returned, even when an error interrupts the run. The whole run, including
+// the planning, holds the Locker, if one is set.
+func (c *Gloat) MigrateTo(version int64) (Plan, error) {
+	return c.MigrateToContext(context.Background(), version)
+}
+
+// MigrateToContext applies or reverts migrations until the store reaches a
+// version. See MigrateTo for the details.
+func (c *Gloat) MigrateToContext(ctx context.Context, version int64) (executed Plan, err error) {
+	err = c.withLock(ctx, func() error {
+		plan, err := c.PlanToContext(ctx, version)
+		if err != nil {
+			return err
+		}
+
+		executed, err = c.run(ctx, plan)
+		return err
+	})
+
+	return
+}
+
+// Steps applies the next n unapplied migrations. If n is negative, the last -n
+// applied migrations are reverted instead. The executed steps are returned,
+// even when an error interrupts the run. The whole run holds the Locker, if one
+// is set.
+func (c *Gloat) Steps(n int) (Plan, error) {
+	return c.StepsContext(context.Background(), n)
+}
+
+// StepsContext applies or reverts n migrations. See Steps for the details.
+func (c *Gloat) StepsContext(ctx context.Context, n int) (executed Plan, err error) {
+	err = c.withLock(ctx, func() error {
+		plan, err := c.PlanStepsContext(ctx, n)
+		if err != nil {
+			return err
+		}
+
+		executed, err = c.run(ctx, plan)
+		return err
+	})
+
+	return
+}
+
+func (c *Gloat) run(ctx context.Context, plan Plan) (executed Plan, err error) {
+	// Render every migration before running any, so a missing variable fails
+	// the run before it changes the database.
+	rendered := make(Migrations, len(plan))
+	for i, step := range plan {
+		if step.Direction == Up {
+			step.Migration.Checksum = c.Checksum.Sum(step.Migration.UpSQL)
+		}
+
+		if rendered[i], err = step.Migration.Render(c.Vars, step.Direction); err != nil {
+			return
+		}
+	}
+
+	for i, step := range plan {
+		switch step.Direction {
+		case Up:
+			c.logf("Applying: %d...", step.Migration.Version)
+			err = c.apply(ctx, step.Migration, rendered[i])
+		case Down:
+			c.logf("Reverting: %d...", step.Migration.Version)
+			err = down(ctx, c.Executor, rendered[i], c.Store)
+		}
+
+		if err != nil {
+			return
+		}
+
+		executed = append(executed, step)
+	}
+
+	return
+}
+
+func (c *Gloat) collectBoth(ctx context.Context) (applied Migrations, available Migrations, err error) {
+	if applied, err = collect(ctx, c.Store); err != nil {
+		return
+	}
+
+	available, err = collect(ctx, c.Source)
+	return
+}
+
+func (c *Gloat) logf(format string, v ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, v...)
+	}
+}
+
+func revertStep(version int64, availableMigrations Migrations) (Step, error) {
+	migration := availableMigrations.Find(version)
+	if migration == nil {
+		return Step{}, MissingMigrationError{version}
+	}
+
+	if !migration.Reversible() {
+		return Step{}, IrreversibleError{version}
+	}
+
+	return Step{Migration: migration, Direction: Down}, nil
+}
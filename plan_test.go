@@ -0,0 +1,152 @@
+package gloat
+
+import (
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func stepVersions(plan Plan) (versions []int64) {
+	for _, step := range plan {
+		versions = append(versions, step.Migration.Version)
+	}
+
+	return
+}
+
+func TestPlanTo_Latest(t *testing.T) {
+	gl.Store = &testingStore{applied: Migrations{}}
+
+	plan, err := gl.PlanTo(Latest)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []int64{20170329154959, 20170511172647, 20180905150724, 20180920181906}, stepVersions(plan))
+	for _, step := range plan {
+		assert.Equal(t, Up, step.Direction)
+	}
+}
+
+func TestPlanTo_Revert(t *testing.T) {
+	gl.Store = &testingStore{
+		applied: Migrations{
+			&Migration{Version: 20170329154959},
+			&Migration{Version: 20170511172647},
+			&Migration{Version: 20180905150724},
+			&Migration{Version: 20180920181906},
+		},
+	}
+
+	plan, err := gl.PlanTo(20180905150724)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []int64{20180920181906}, stepVersions(plan))
+	for _, step := range plan {
+		assert.Equal(t, Down, step.Direction)
+	}
+}
+
+func TestPlanTo_OutOfOrder(t *testing.T) {
+	gl.Store = &testingStore{
+		applied: Migrations{
+			&Migration{Version: 20170329154959},
+			&Migration{Version: 20180920181906},
+		},
+	}
+
+	plan, err := gl.PlanTo(20170511172647)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []int64{20180920181906, 20170511172647}, stepVersions(plan))
+	assert.Equal(t, Down, plan[0].Direction)
+	assert.Equal(t, Up, plan[1].Direction)
+}
+
+func TestPlanTo_Irreversible(t *testing.T) {
+	gl.Store = &testingStore{
+		applied: Migrations{
+			&Migration{Version: 20170329154959},
+			&Migration{Version: 20170511172647},
+		},
+	}
+
+	_, err := gl.PlanTo(0)
+	assert.Equal(t, IrreversibleError{20170511172647}, err)
+}
+
+func TestPlanTo_Missing(t *testing.T) {
+	gl.Store = &testingStore{
+		applied: Migrations{
+			&Migration{Version: 20170329154959},
+			&Migration{Version: 20190101000000},
+		},
+	}
+
+	_, err := gl.PlanTo(20170329154959)
+	assert.Equal(t, MissingMigrationError{20190101000000}, err)
+}
+
+func TestPlanTo_Unknown(t *testing.T) {
+	gl.Store = &testingStore{applied: Migrations{}}
+
+	_, err := gl.PlanTo(42)
+	assert.Error(t, err)
+}
+
+func TestPlanSteps(t *testing.T) {
+	gl.Store = &testingStore{
+		applied: Migrations{
+			&Migration{Version: 20170329154959},
+		},
+	}
+
+	plan, err := gl.PlanSteps(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{20170511172647, 20180905150724}, stepVersions(plan))
+
+	plan, err = gl.PlanSteps(10)
+	assert.Nil(t, err)
+	assert.Len(t, 3, plan)
+
+	plan, err = gl.PlanSteps(-1)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{20170329154959}, stepVersions(plan))
+	assert.Equal(t, Down, plan[0].Direction)
+}
+
+func TestMigrateTo(t *testing.T) {
+	var applied []int64
+
+	gl.Store = &testingStore{applied: Migrations{}}
+	gl.Executor = &stubbedExecutor{
+		up: func(m *Migration, _ Store) error {
+			applied = append(applied, m.Version)
+			return nil
+		},
+	}
+	defer func() { gl.Executor = &testingExecutor{} }()
+
+	plan, err := gl.MigrateTo(20170511172647)
+	assert.Nil(t, err)
+
+	assert.Len(t, 2, plan)
+	assert.Equal(t, []int64{20170329154959, 20170511172647}, applied)
+}
+
+func TestSteps_StopsOnError(t *testing.T) {
+	gl.Store = &testingStore{applied: Migrations{}}
+	gl.Executor = &stubbedExecutor{
+		up: func(m *Migration, _ Store) error {
+			if m.Version == 20170511172647 {
+				return IrreversibleError{m.Version}
+			}
+
+			return nil
+		},
+	}
+	defer func() { gl.Executor = &testingExecutor{} }()
+
+	plan, err := gl.Steps(3)
+	assert.Error(t, err)
+
+	assert.Equal(t, []int64{20170329154959}, stepVersions(plan))
+}
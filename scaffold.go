@@ -0,0 +1,296 @@
+package gloat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// MigrationKind is the layout of a generated migration.
+type MigrationKind string
+
+// The layouts a migration can be generated in.
+const (
+	// DirectoryMigration is a folder with up.sql, down.sql and, if the
+	// options differ from the defaults, options.json.
+	DirectoryMigration MigrationKind = "sql"
+
+	// SingleFileMigration is one .sql file with up and down sections. See
+	// MigrationFromFile for its format.
+	SingleFileMigration MigrationKind = "single"
+
+	// GoMigration is a .go file registering the migration with a GoSource.
+	GoMigration MigrationKind = "go"
+)
+
+var goPackageNameRe = regexp.MustCompile(`[^a-z0-9_]`)
+
+// MigrationTemplateData is what the migration templates are rendered with.
+type MigrationTemplateData struct {
+	// Version is the version of the migration, like 20170329154959.
+	Version int64
+
+	// Name is the name of the migration, like introduce_domain_model.
+	Name string
+
+	// Down is false for migrations generated without a down side.
+	Down bool
+
+	// Options are the options of the migration.
+	Options MigrationOptions
+
+	// OptionsJSON is the options encoded as JSON, or blank if they are the
+	// defaults.
+	OptionsJSON string
+
+	// OptionsGo is the options as a Go composite literal, like
+	// gloat.MigrationOptions{Split: true}, or blank if they are the defaults.
+	OptionsGo string
+
+	// Package is the Go package of Go migrations, named after the folder
+	// they are generated in.
+	Package string
+}
+
+// MigrationTemplates are the templates new migrations are rendered from. They
+// are text/template templates, rendered with MigrationTemplateData.
+type MigrationTemplates struct {
+	Up     *template.Template
+	Down   *template.Template
+	Single *template.Template
+	Go     *template.Template
+}
+
+// migrationTemplateFiles are the files ParseMigrationTemplates looks for.
+var migrationTemplateFiles = map[string]func(*MigrationTemplates) **template.Template{
+	"up.sql.tmpl":     func(t *MigrationTemplates) **template.Template { return &t.Up },
+	"down.sql.tmpl":   func(t *MigrationTemplates) **template.Template { return &t.Down },
+	"single.sql.tmpl": func(t *MigrationTemplates) **template.Template { return &t.Single },
+	"go.tmpl":         func(t *MigrationTemplates) **template.Template { return &t.Go },
+}
+
+const (
+	defaultUpTemplate = `-- Write the SQL applying {{.Name}} here.
+`
+
+	// The down side is left empty, as a down.sql with comments only would
+	// make the migration reversible, while reverting it does nothing.
+	defaultDownTemplate = ``
+
+	defaultSingleTemplate = `{{if .OptionsJSON}}-- gloat:options {{.OptionsJSON}}
+
+{{end}}-- gloat:up
+-- Write the SQL applying {{.Name}} here.
+{{if .Down}}
+-- Write the SQL reverting {{.Name}} under gloat:down. The migration is
+-- irreversible until then.
+-- gloat:down
+{{end}}`
+
+	defaultGoTemplate = `package {{.Package}}
+
+import (
+	"context"
+
+	"github.com/gsamokovarov/gloat"
+)
+
+func init() {
+{{- if .OptionsJSON}}
+	goMigrations.RegisterWithOptions({{.Version}}, "{{.Name}}", {{.OptionsGo}}, up{{.Version}}, {{if .Down}}down{{.Version}}{{else}}nil{{end}})
+{{- else}}
+	goMigrations.Register({{.Version}}, "{{.Name}}", up{{.Version}}, {{if .Down}}down{{.Version}}{{else}}nil{{end}})
+{{- end}}
+}
+
+func up{{.Version}}(ctx context.Context, tx gloat.SQLExecer) error {
+	return nil
+}
+{{- if .Down}}
+
+func down{{.Version}}(ctx context.Context, tx gloat.SQLExecer) error {
+	return nil
+}
+{{- end}}
+`
+)
+
+// DefaultMigrationTemplates returns the builtin migration templates. The SQL
+// ones leave a comment to fill in. The Go one registers the migration with a
+// GoSource called goMigrations, that the package has to declare.
+func DefaultMigrationTemplates() *MigrationTemplates {
+	return &MigrationTemplates{
+		Up:     template.Must(template.New("up.sql.tmpl").Parse(defaultUpTemplate)),
+		Down:   template.Must(template.New("down.sql.tmpl").Parse(defaultDownTemplate)),
+		Single: template.Must(template.New("single.sql.tmpl").Parse(defaultSingleTemplate)),
+		Go:     template.Must(template.New("go.tmpl").Parse(defaultGoTemplate)),
+	}
+}
+
+// ParseMigrationTemplates reads migration templates from a folder. It looks
+// for up.sql.tmpl, down.sql.tmpl, single.sql.tmpl and go.tmpl. The missing
+// ones fall back to the builtin templates.
+func ParseMigrationTemplates(dir string) (*MigrationTemplates, error) {
+	templates := DefaultMigrationTemplates()
+
+	for name, field := range migrationTemplateFiles {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		tmpl, err := template.New(name).Parse(string(content))
+		if err != nil {
+			return nil, err
+		}
+
+		*field(templates) = tmpl
+	}
+
+	return templates, nil
+}
+
+// Scaffold generates the files of new migrations from templates.
+type Scaffold struct {
+	// Kind is the layout of the generated migrations. Defaults to
+	// DirectoryMigration.
+	Kind MigrationKind
+
+	// Templates are the templates the files are rendered from. Defaults to
+	// DefaultMigrationTemplates.
+	Templates *MigrationTemplates
+
+	// NoDown generates irreversible migrations, without a down side.
+	NoDown bool
+}
+
+// Write renders a migration, like the ones from GenerateMigration, into a
+// folder. The options of the migration are written as well, if they differ
+// from the defaults. The path of the written folder or file is returned.
+func (s *Scaffold) Write(dir string, migration *Migration) (string, error) {
+	templates := s.Templates
+	if templates == nil {
+		templates = DefaultMigrationTemplates()
+	}
+
+	data := MigrationTemplateData{
+		Version: migration.Version,
+		Name:    migration.Name(),
+		Down:    !s.NoDown,
+		Options: migration.Options,
+		Package: goPackageName(dir),
+	}
+
+	if !migration.Options.IsDefault() {
+		optionsJSON, err := json.Marshal(migration.Options)
+		if err != nil {
+			return "", err
+		}
+
+		data.OptionsJSON = string(optionsJSON)
+		data.OptionsGo = goOptionsLiteral(migration.Options)
+	}
+
+	base := filepath.Base(migration.Path)
+
+	switch s.Kind {
+	case DirectoryMigration, "":
+		return s.writeDirectory(filepath.Join(dir, base), templates, data)
+	case SingleFileMigration:
+		return s.writeFile(filepath.Join(dir, base+MigrationFileExt), templates.Single, data)
+	case GoMigration:
+		return s.writeFile(filepath.Join(dir, base+".go"), templates.Go, data)
+	}
+
+	return "", fmt.Errorf("unknown migration kind %q", s.Kind)
+}
+
+func (s *Scaffold) writeDirectory(path string, templates *MigrationTemplates, data MigrationTemplateData) (string, error) {
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", err
+	}
+
+	if err := renderFile(filepath.Join(path, "up.sql"), templates.Up, data); err != nil {
+		return "", err
+	}
+
+	if data.Down {
+		if err := renderFile(filepath.Join(path, "down.sql"), templates.Down, data); err != nil {
+			return "", err
+		}
+	}
+
+	if data.OptionsJSON != "" {
+		options, err := json.MarshalIndent(data.Options, "", "  ")
+		if err != nil {
+			return "", err
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(path, "options.json"), append(options, '\n'), 0644); err != nil {
+			return "", err
+		}
+	}
+
+	return path, nil
+}
+
+func (s *Scaffold) writeFile(path string, tmpl *template.Template, data MigrationTemplateData) (string, error) {
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+
+	return path, renderFile(path, tmpl, data)
+}
+
+func renderFile(path string, tmpl *template.Template, data MigrationTemplateData) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// goOptionsLiteral renders migration options as a Go composite literal. The
+// fields with zero values are left out.
+func goOptionsLiteral(options MigrationOptions) string {
+	value := reflect.ValueOf(options)
+
+	var fields []string
+	for i := 0; i < value.NumField(); i++ {
+		if field := value.Field(i); !field.IsZero() {
+			fields = append(fields, fmt.Sprintf("%s: %#v", value.Type().Field(i).Name, field.Interface()))
+		}
+	}
+
+	return "gloat.MigrationOptions{" + strings.Join(fields, ", ") + "}"
+}
+
+// goPackageName turns the name of a folder into a Go package name, like
+// migrations for db/migrations.
+func goPackageName(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	name := goPackageNameRe.ReplaceAllString(strings.ToLower(filepath.Base(dir)), "_")
+	if name == "" || name == "_" || (name[0] >= '0' && name[0] <= '9') {
+		return "migrations"
+	}
+
+	return name
+}
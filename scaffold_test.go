@@ -0,0 +1,159 @@
+package gloat
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func TestScaffold_Directory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gloat")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	migration := &Migration{
+		Path:    "20170329154959_introduce_domain_model",
+		Version: 20170329154959,
+		Options: DefaultMigrationOptions(),
+	}
+
+	path, err := (&Scaffold{}).Write(dir, migration)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "20170329154959_introduce_domain_model"), path)
+
+	generated, err := MigrationFromBytes(path, ioutil.ReadFile)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 20170329154959, generated.Version)
+	assert.False(t, generated.Reversible())
+	assert.Equal(t, DefaultMigrationOptions(), generated.Options)
+
+	down, err := ioutil.ReadFile(filepath.Join(path, "down.sql"))
+	assert.Nil(t, err)
+	assert.Len(t, 0, down)
+
+	_, err = os.Stat(filepath.Join(path, "options.json"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = (&Scaffold{}).Write(dir, migration)
+	assert.Error(t, err)
+}
+
+func TestScaffold_DirectoryWithoutDownAndTransaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gloat")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	migration := &Migration{
+		Path:    "20180905150724_concurrent_migration",
+		Version: 20180905150724,
+		Options: MigrationOptions{Transaction: false, Split: true},
+	}
+
+	path, err := (&Scaffold{NoDown: true}).Write(dir, migration)
+	assert.Nil(t, err)
+
+	generated, err := MigrationFromBytes(path, ioutil.ReadFile)
+	assert.Nil(t, err)
+
+	assert.False(t, generated.Reversible())
+	assert.False(t, generated.Options.Transaction)
+	assert.True(t, generated.Options.Split)
+}
+
+func TestScaffold_SingleFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gloat")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, noDown := range []bool{false, true} {
+		migration := &Migration{
+			Path:    "20180905150724_concurrent_migration",
+			Version: 20180905150724,
+			Options: MigrationOptions{Transaction: false, Split: true},
+		}
+
+		path, err := (&Scaffold{Kind: SingleFileMigration, NoDown: noDown}).Write(dir, migration)
+		assert.Nil(t, err)
+
+		generated, err := MigrationFromFile(path, ioutil.ReadFile)
+		assert.Nil(t, err)
+
+		assert.Equal(t, 20180905150724, generated.Version)
+		assert.False(t, generated.Reversible())
+		assert.False(t, generated.Options.Transaction)
+
+		content, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, !noDown, strings.Contains(string(content), "-- gloat:down"))
+
+		assert.Nil(t, os.Remove(path))
+	}
+}
+
+func TestScaffold_Go(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gloat")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	migrationsDir := filepath.Join(dir, "migrations")
+	assert.Nil(t, os.Mkdir(migrationsDir, 0755))
+
+	for _, options := range []MigrationOptions{DefaultMigrationOptions(), {Transaction: false}, {Split: true, Template: true}} {
+		migration := &Migration{
+			Path:    "20180905150724_backfill_user_tokens",
+			Version: 20180905150724,
+			Options: options,
+		}
+
+		path, err := (&Scaffold{Kind: GoMigration}).Write(migrationsDir, migration)
+		assert.Nil(t, err)
+
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, "migrations", file.Name.Name)
+
+		content, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		assert.True(t, strings.Contains(string(content), ", down20180905150724)"))
+
+		if options.Template {
+			assert.True(t, strings.Contains(string(content), "gloat.MigrationOptions{Split: true, Template: true}"))
+		}
+
+		assert.Nil(t, os.Remove(path))
+	}
+}
+
+func TestParseMigrationTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gloat")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "up.sql.tmpl"), []byte("-- {{.Version}} {{.Name}}\nSELECT 1;\n"), 0644)
+	assert.Nil(t, err)
+
+	templates, err := ParseMigrationTemplates(dir)
+	assert.Nil(t, err)
+
+	migration := &Migration{
+		Path:    "20170329154959_introduce_domain_model",
+		Version: 20170329154959,
+		Options: DefaultMigrationOptions(),
+	}
+
+	path, err := (&Scaffold{Templates: templates}).Write(dir, migration)
+	assert.Nil(t, err)
+
+	generated, err := MigrationFromBytes(path, ioutil.ReadFile)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "-- 20170329154959 introduce_domain_model\nSELECT 1;\n", string(generated.UpSQL))
+	assert.False(t, generated.Reversible())
+}
@@ -0,0 +1,292 @@
+package gloat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SchemaObject is a table, index, view or another object of a database
+// schema, along with the SQL creating it.
+type SchemaObject struct {
+	// Type is the kind of the object, like table, index or view.
+	Type string
+
+	// Name is the name of the object. Objects that belong to a table, like
+	// constraints and triggers, are prefixed with the table name.
+	Name string
+
+	// Table is the table the object belongs to. It is the object name itself
+	// for tables.
+	Table string
+
+	// SQL is the statement creating the object, without the trailing
+	// semicolon.
+	SQL string
+
+	// DependsOn are the names of the objects that have to be created before
+	// this one, like the views a view selects from.
+	DependsOn []string
+}
+
+// SchemaDumper introspects the structure of a database.
+type SchemaDumper interface {
+	DumpSchema(context.Context) ([]SchemaObject, error)
+}
+
+// schemaObjectOrder is the order the objects are written in a structure
+// file, so they can be created in it. Functions go before the tables, as
+// column defaults, checks and generated columns can call them.
+var schemaObjectOrder = map[string]int{
+	"setting":           0,
+	"function":          1,
+	"sequence":          2,
+	"table":             3,
+	"constraint":        4,
+	"index":             5,
+	"foreign key":       6,
+	"view":              7,
+	"materialized view": 8,
+	"trigger":           9,
+}
+
+// SchemaAppliedDirective marks the applied migration versions in structure
+// files.
+const SchemaAppliedDirective = "-- gloat:applied"
+
+// DumpSchema writes the structure of the database and the versions of the
+// applied migrations, so the database can be recreated from it with
+// LoadSchema. The objects are sorted, so the output only changes with the
+// schema. The tables of the Store and the Locker are left out.
+func (c *Gloat) DumpSchema(w io.Writer, dumper SchemaDumper) error {
+	return c.DumpSchemaContext(context.Background(), w, dumper)
+}
+
+// DumpSchemaContext writes the structure of the database and the versions of
+// the applied migrations. See DumpSchema for the details.
+func (c *Gloat) DumpSchemaContext(ctx context.Context, w io.Writer, dumper SchemaDumper) error {
+	objects, err := dumper.DumpSchema(ctx)
+	if err != nil {
+		return err
+	}
+
+	appliedMigrations, err := collect(ctx, c.Store)
+	if err != nil {
+		return err
+	}
+
+	excluded := make(map[string]bool)
+	if store, ok := c.Store.(*DatabaseStore); ok {
+		excluded[store.table] = true
+	}
+	if locker, ok := c.Locker.(*TableLocker); ok {
+		excluded[locker.table] = true
+	}
+
+	var included []SchemaObject
+	for _, object := range objects {
+		if !excluded[object.Table] {
+			included = append(included, object)
+		}
+	}
+
+	return writeSchema(w, included, appliedMigrations)
+}
+
+func writeSchema(w io.Writer, objects []SchemaObject, appliedMigrations Migrations) error {
+	objects = sortSchemaObjects(objects)
+
+	var schema bytes.Buffer
+
+	fmt.Fprintf(&schema, "-- The structure of the database, dumped by gloat.\n")
+	fmt.Fprintf(&schema, "-- Do not edit it by hand, regenerate it with gloat schema dump.\n\n")
+
+	for _, object := range objects {
+		fmt.Fprintf(&schema, "%s;\n\n", strings.TrimRight(strings.TrimSpace(object.SQL), ";"))
+	}
+
+	migrations := make(Migrations, len(appliedMigrations))
+	copy(migrations, appliedMigrations)
+	sort.Sort(migrations)
+
+	for _, migration := range migrations {
+		fmt.Fprintf(&schema, "%s %d", SchemaAppliedDirective, migration.Version)
+		if name := migration.Name(); name != "" {
+			fmt.Fprintf(&schema, " %s", name)
+		}
+		fmt.Fprintf(&schema, "\n")
+	}
+
+	_, err := schema.WriteTo(w)
+	return err
+}
+
+// sortSchemaObjects sorts the objects by type and name, and then moves the
+// objects after their dependencies. Dependency cycles are left as they are.
+func sortSchemaObjects(objects []SchemaObject) []SchemaObject {
+	sort.SliceStable(objects, func(i, j int) bool {
+		if objects[i].Type != objects[j].Type {
+			return schemaObjectOrder[objects[i].Type] < schemaObjectOrder[objects[j].Type]
+		}
+
+		return objects[i].Name < objects[j].Name
+	})
+
+	pending := make(map[string]bool)
+	for _, object := range objects {
+		pending[object.Name] = true
+	}
+
+	sorted := make([]SchemaObject, 0, len(objects))
+	written := make([]bool, len(objects))
+
+	for len(sorted) < len(objects) {
+		next := -1
+		for i, object := range objects {
+			if written[i] {
+				continue
+			}
+			if next == -1 {
+				next = i
+			}
+
+			ready := true
+			for _, dependency := range object.DependsOn {
+				if dependency != object.Name && pending[dependency] {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				next = i
+				break
+			}
+		}
+
+		written[next] = true
+		pending[objects[next].Name] = false
+		sorted = append(sorted, objects[next])
+	}
+
+	return sorted
+}
+
+// LoadSchema executes a structure file, like the ones written by DumpSchema,
+// and records the migrations listed in it as applied. It bootstraps fresh
+// databases without replaying every migration, so later runs apply only the
+// newer ones. The statements and the records run in one transaction, except on
+// MySQL, which commits every schema change on its own. Loading a schema into a
+// database with applied migrations is an error. The recorded migrations are
+// returned.
+func (c *Gloat) LoadSchema(r io.Reader, db SQLTransactor, dialect Dialect) (Migrations, error) {
+	return c.LoadSchemaContext(context.Background(), r, db, dialect)
+}
+
+// LoadSchemaContext executes a structure file and records the migrations
+// listed in it as applied. See LoadSchema for the details.
+func (c *Gloat) LoadSchemaContext(ctx context.Context, r io.Reader, db SQLTransactor, dialect Dialect) (loaded Migrations, err error) {
+	schema, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	statements, err := SplitStatements(schema, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaMigrations, err := parseSchemaApplied(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.withLock(ctx, func() error {
+		appliedMigrations, availableMigrations, err := c.collectBoth(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(appliedMigrations) > 0 {
+			return errors.New("cannot load a schema into a database with applied migrations")
+		}
+
+		// Prefer the migrations from the source, so their checksums are
+		// recorded. The ones squashed or archived since are recorded by
+		// version and name only.
+		for i, migration := range schemaMigrations {
+			if available := availableMigrations.Find(migration.Version); available != nil {
+				available.Checksum = c.Checksum.Sum(available.UpSQL)
+				schemaMigrations[i] = available
+			}
+		}
+
+		if err := setup(ctx, c.Store); err != nil {
+			return err
+		}
+
+		tx, err := beginTx(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement.SQL); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		for _, migration := range schemaMigrations {
+			if err := insert(ctx, c.Store, migration, tx); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		loaded = schemaMigrations
+		return nil
+	})
+
+	return
+}
+
+// parseSchemaApplied parses the applied migration directives of a structure
+// file, like -- gloat:applied 20170329154959 introduce_domain_model.
+func parseSchemaApplied(schema []byte) (Migrations, error) {
+	var migrations Migrations
+
+	scanner := bufio.NewScanner(bytes.NewReader(schema))
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, SchemaAppliedDirective+" ") {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(line, SchemaAppliedDirective))
+		version, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid applied migration version %q", lineno, fields[0])
+		}
+
+		path := fields[0]
+		if len(fields) > 1 {
+			path += "_" + fields[1]
+		}
+
+		migrations = append(migrations, &Migration{Version: version, Path: path})
+	}
+
+	return migrations, scanner.Err()
+}
@@ -0,0 +1,428 @@
+package gloat
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SQLite3SchemaDumper dumps the structure of an SQLite3 database from the
+// sqlite_master catalog.
+type SQLite3SchemaDumper struct {
+	db SQLExecer
+}
+
+// DumpSchema implements the SchemaDumper interface.
+func (d *SQLite3SchemaDumper) DumpSchema(ctx context.Context) (objects []SchemaObject, err error) {
+	rows, err := queryContext(ctx, d.db, `
+		SELECT type, name, tbl_name, sql
+		FROM sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var object SchemaObject
+		if err = rows.Scan(&object.Type, &object.Name, &object.Table, &object.SQL); err != nil {
+			return
+		}
+
+		objects = append(objects, object)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// NewSQLite3SchemaDumper creates a SchemaDumper for SQLite3.
+func NewSQLite3SchemaDumper(db SQLExecer) SchemaDumper {
+	return &SQLite3SchemaDumper{db: db}
+}
+
+// PostgreSQLSchemaDumper dumps the structure of the current schema of a
+// PostgreSQL database from the pg_catalog. It covers sequences, tables,
+// constraints, indexes, views, functions and triggers. Objects created by
+// extensions are left out. It needs PostgreSQL 12 or newer.
+//
+// The functions are dumped before the tables and the function bodies are not
+// checked while loading, so SQL functions can select from tables created
+// after them. The views are dumped after the views they select from.
+type PostgreSQLSchemaDumper struct {
+	db SQLExecer
+}
+
+// DumpSchema implements the SchemaDumper interface.
+func (d *PostgreSQLSchemaDumper) DumpSchema(ctx context.Context) ([]SchemaObject, error) {
+	objects := []SchemaObject{{
+		Type: "setting",
+		Name: "check_function_bodies",
+		SQL:  "SET LOCAL check_function_bodies = false",
+	}}
+
+	for _, dump := range []func(context.Context) ([]SchemaObject, error){
+		d.sequences,
+		d.tables,
+		d.constraints,
+		d.indexes,
+		d.views,
+		d.functions,
+		d.triggers,
+	} {
+		dumped, err := dump(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, dumped...)
+	}
+
+	return objects, nil
+}
+
+func (d *PostgreSQLSchemaDumper) sequences(ctx context.Context) ([]SchemaObject, error) {
+	return d.query(ctx, `
+		SELECT 'sequence', c.relname, c.relname, 'CREATE SEQUENCE ' || quote_ident(c.relname)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind = 'S' AND n.nspname = current_schema()
+			AND NOT EXISTS (
+				SELECT 1 FROM pg_depend dep
+				WHERE dep.objid = c.oid AND dep.deptype IN ('i', 'e')
+			)`)
+}
+
+func (d *PostgreSQLSchemaDumper) tables(ctx context.Context) (objects []SchemaObject, err error) {
+	rows, err := queryContext(ctx, d.db, `
+		SELECT c.relname, a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull,
+			COALESCE(pg_get_expr(def.adbin, def.adrelid), ''), a.attidentity, a.attgenerated
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_attrdef def ON def.adrelid = a.attrelid AND def.adnum = a.attnum
+		WHERE c.relkind IN ('r', 'p') AND n.nspname = current_schema()
+			AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY c.relname, a.attnum`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	var (
+		table   string
+		columns []string
+	)
+
+	flush := func() {
+		if table != "" {
+			objects = append(objects, SchemaObject{
+				Type:  "table",
+				Name:  table,
+				Table: table,
+				SQL:   fmt.Sprintf("CREATE TABLE %s (\n    %s\n)", PostgreSQL.QuoteIdentifier(table), strings.Join(columns, ",\n    ")),
+			})
+		}
+	}
+
+	for rows.Next() {
+		var (
+			tableName, column, columnType, defaultValue, identity, generated string
+			notNull                                                          bool
+		)
+
+		if err = rows.Scan(&tableName, &column, &columnType, &notNull, &defaultValue, &identity, &generated); err != nil {
+			return
+		}
+
+		if tableName != table {
+			flush()
+			table, columns = tableName, nil
+		}
+
+		definition := PostgreSQL.QuoteIdentifier(column) + " " + columnType
+		switch {
+		case identity == "a":
+			definition += " GENERATED ALWAYS AS IDENTITY"
+		case identity == "d":
+			definition += " GENERATED BY DEFAULT AS IDENTITY"
+		case generated == "s":
+			definition += " GENERATED ALWAYS AS (" + defaultValue + ") STORED"
+		case defaultValue != "":
+			definition += " DEFAULT " + defaultValue
+		}
+		if notNull {
+			definition += " NOT NULL"
+		}
+
+		columns = append(columns, definition)
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	flush()
+
+	return
+}
+
+func (d *PostgreSQLSchemaDumper) constraints(ctx context.Context) ([]SchemaObject, error) {
+	return d.query(ctx, `
+		SELECT CASE con.contype WHEN 'f' THEN 'foreign key' ELSE 'constraint' END,
+			c.relname || '.' || con.conname,
+			c.relname,
+			'ALTER TABLE ' || quote_ident(c.relname) || ' ADD CONSTRAINT ' || quote_ident(con.conname) || ' ' || pg_get_constraintdef(con.oid)
+		FROM pg_constraint con
+		JOIN pg_class c ON c.oid = con.conrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE con.contype IN ('p', 'u', 'c', 'f', 'x') AND n.nspname = current_schema()`)
+}
+
+func (d *PostgreSQLSchemaDumper) indexes(ctx context.Context) ([]SchemaObject, error) {
+	return d.query(ctx, `
+		SELECT 'index', i.relname, t.relname, pg_get_indexdef(i.oid)
+		FROM pg_index x
+		JOIN pg_class i ON i.oid = x.indexrelid
+		JOIN pg_class t ON t.oid = x.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE n.nspname = current_schema()
+			AND NOT EXISTS (SELECT 1 FROM pg_constraint con WHERE con.conindid = i.oid)`)
+}
+
+func (d *PostgreSQLSchemaDumper) views(ctx context.Context) (objects []SchemaObject, err error) {
+	rows, err := queryContext(ctx, d.db, `
+		SELECT CASE c.relkind WHEN 'v' THEN 'view' ELSE 'materialized view' END,
+			c.relname,
+			CASE c.relkind WHEN 'v' THEN 'CREATE VIEW ' ELSE 'CREATE MATERIALIZED VIEW ' END ||
+				quote_ident(c.relname) || E' AS\n' || rtrim(pg_get_viewdef(c.oid), ';'),
+			COALESCE((
+				SELECT string_agg(DISTINCT ref.relname, ',')
+				FROM pg_rewrite r
+				JOIN pg_depend dep ON dep.classid = 'pg_rewrite'::regclass AND dep.objid = r.oid
+				JOIN pg_class ref ON ref.oid = dep.refobjid
+				WHERE r.ev_class = c.oid AND ref.oid <> c.oid AND ref.relkind IN ('v', 'm')
+			), '')
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('v', 'm') AND n.nspname = current_schema()`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			object       SchemaObject
+			dependencies string
+		)
+
+		if err = rows.Scan(&object.Type, &object.Name, &object.SQL, &dependencies); err != nil {
+			return
+		}
+
+		object.Table = object.Name
+		if dependencies != "" {
+			object.DependsOn = strings.Split(dependencies, ",")
+		}
+
+		objects = append(objects, object)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+func (d *PostgreSQLSchemaDumper) functions(ctx context.Context) ([]SchemaObject, error) {
+	return d.query(ctx, `
+		SELECT 'function', p.proname || '(' || pg_get_function_identity_arguments(p.oid) || ')', '', pg_get_functiondef(p.oid)
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname = current_schema()
+			AND p.oid NOT IN (SELECT aggfnoid FROM pg_aggregate)
+			AND NOT EXISTS (
+				SELECT 1 FROM pg_depend dep
+				WHERE dep.objid = p.oid AND dep.deptype = 'e'
+			)`)
+}
+
+func (d *PostgreSQLSchemaDumper) triggers(ctx context.Context) ([]SchemaObject, error) {
+	return d.query(ctx, `
+		SELECT 'trigger', c.relname || '.' || t.tgname, c.relname, pg_get_triggerdef(t.oid)
+		FROM pg_trigger t
+		JOIN pg_class c ON c.oid = t.tgrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE NOT t.tgisinternal AND n.nspname = current_schema()`)
+}
+
+func (d *PostgreSQLSchemaDumper) query(ctx context.Context, query string) (objects []SchemaObject, err error) {
+	rows, err := queryContext(ctx, d.db, query)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var object SchemaObject
+		if err = rows.Scan(&object.Type, &object.Name, &object.Table, &object.SQL); err != nil {
+			return
+		}
+
+		objects = append(objects, object)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// NewPostgreSQLSchemaDumper creates a SchemaDumper for PostgreSQL.
+func NewPostgreSQLSchemaDumper(db SQLExecer) SchemaDumper {
+	return &PostgreSQLSchemaDumper{db: db}
+}
+
+var (
+	mysqlAutoIncrementRe = regexp.MustCompile(` AUTO_INCREMENT=\d+`)
+	mysqlDefinerRe       = regexp.MustCompile(` DEFINER=\S+`)
+	mysqlForeignKeyRe    = regexp.MustCompile("^\\s*CONSTRAINT `([^`]+)` FOREIGN KEY .*?,?$")
+)
+
+// MySQLSchemaDumper dumps the structure of a MySQL database from the
+// information_schema and SHOW CREATE statements. It covers tables, their
+// indexes and foreign keys, and views. The foreign keys are split out of the
+// tables, so the tables can be created in any order.
+type MySQLSchemaDumper struct {
+	db SQLExecer
+}
+
+// DumpSchema implements the SchemaDumper interface.
+func (d *MySQLSchemaDumper) DumpSchema(ctx context.Context) (objects []SchemaObject, err error) {
+	rows, err := queryContext(ctx, d.db, `
+		SELECT table_name, table_type
+		FROM information_schema.tables
+		WHERE table_schema = DATABASE()`)
+	if err != nil {
+		return
+	}
+
+	var tables, views []string
+	for rows.Next() {
+		var name, tableType string
+		if err = rows.Scan(&name, &tableType); err != nil {
+			rows.Close()
+			return
+		}
+
+		if tableType == "VIEW" {
+			views = append(views, name)
+		} else {
+			tables = append(tables, name)
+		}
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	for _, table := range tables {
+		var createTable string
+		if err = d.showCreate(ctx, "SHOW CREATE TABLE "+MySQL.QuoteIdentifier(table), &createTable); err != nil {
+			return
+		}
+
+		objects = append(objects, mysqlTableObjects(table, createTable)...)
+	}
+
+	for _, view := range views {
+		var createView string
+		if err = d.showCreate(ctx, "SHOW CREATE VIEW "+MySQL.QuoteIdentifier(view), &createView); err != nil {
+			return
+		}
+
+		objects = append(objects, SchemaObject{
+			Type:  "view",
+			Name:  view,
+			Table: view,
+			SQL:   mysqlDefinerRe.ReplaceAllString(createView, ""),
+		})
+	}
+
+	return
+}
+
+// showCreate scans the second column of a SHOW CREATE statement, which is the
+// statement creating the object.
+func (d *MySQLSchemaDumper) showCreate(ctx context.Context, query string, statement *string) error {
+	rows, err := queryContext(ctx, d.db, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(columns))
+	for i := range values {
+		values[i] = new(string)
+	}
+	values[1] = statement
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("%s returned no rows", query)
+	}
+
+	return rows.Scan(values...)
+}
+
+func mysqlTableObjects(table, createTable string) []SchemaObject {
+	createTable = mysqlAutoIncrementRe.ReplaceAllString(createTable, "")
+
+	var (
+		lines       []string
+		foreignKeys []SchemaObject
+	)
+
+	for _, line := range strings.Split(createTable, "\n") {
+		match := mysqlForeignKeyRe.FindStringSubmatch(line)
+		if match == nil {
+			lines = append(lines, line)
+			continue
+		}
+
+		foreignKeys = append(foreignKeys, SchemaObject{
+			Type:  "foreign key",
+			Name:  table + "." + match[1],
+			Table: table,
+			SQL:   fmt.Sprintf("ALTER TABLE %s ADD %s", MySQL.QuoteIdentifier(table), strings.TrimSuffix(strings.TrimSpace(line), ",")),
+		})
+	}
+
+	// Removing the foreign keys, which come last, can leave a trailing comma
+	// before the closing parenthesis.
+	for i := len(lines) - 1; i > 0; i-- {
+		if strings.HasPrefix(lines[i], ")") {
+			lines[i-1] = strings.TrimSuffix(lines[i-1], ",")
+			break
+		}
+	}
+
+	objects := []SchemaObject{{Type: "table", Name: table, Table: table, SQL: strings.Join(lines, "\n")}}
+
+	return append(objects, foreignKeys...)
+}
+
+// NewMySQLSchemaDumper creates a SchemaDumper for MySQL.
+func NewMySQLSchemaDumper(db SQLExecer) SchemaDumper {
+	return &MySQLSchemaDumper{db: db}
+}
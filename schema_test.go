@@ -0,0 +1,217 @@
+package gloat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func TestWriteSchema(t *testing.T) {
+	objects := []SchemaObject{
+		{Type: "index", Name: "users_email", Table: "users", SQL: "CREATE INDEX users_email ON users (email)"},
+		{Type: "view", Name: "admins", Table: "admins", SQL: "CREATE VIEW admins AS SELECT * FROM users;"},
+		{Type: "table", Name: "users", Table: "users", SQL: "CREATE TABLE users (id INTEGER, email TEXT)"},
+		{Type: "table", Name: "posts", Table: "posts", SQL: "CREATE TABLE posts (id INTEGER)"},
+	}
+
+	applied := Migrations{
+		&Migration{Version: 20180905150724, Path: "20180905150724_add_posts"},
+		&Migration{Version: 20170329154959, Path: "20170329154959_introduce_domain_model"},
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, writeSchema(&buf, objects, applied))
+
+	expected := `-- The structure of the database, dumped by gloat.
+-- Do not edit it by hand, regenerate it with gloat schema dump.
+
+CREATE TABLE posts (id INTEGER);
+
+CREATE TABLE users (id INTEGER, email TEXT);
+
+CREATE INDEX users_email ON users (email);
+
+CREATE VIEW admins AS SELECT * FROM users;
+
+-- gloat:applied 20170329154959 introduce_domain_model
+-- gloat:applied 20180905150724 add_posts
+`
+
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWriteSchema_Dependencies(t *testing.T) {
+	objects := []SchemaObject{
+		{Type: "view", Name: "active_admins", Table: "active_admins", SQL: "CREATE VIEW active_admins AS SELECT * FROM admins", DependsOn: []string{"admins"}},
+		{Type: "view", Name: "admins", Table: "admins", SQL: "CREATE VIEW admins AS SELECT * FROM users"},
+		{Type: "table", Name: "users", Table: "users", SQL: "CREATE TABLE users (id INTEGER DEFAULT next_id())"},
+		{Type: "function", Name: "next_id()", SQL: "CREATE FUNCTION next_id() RETURNS INTEGER AS 'SELECT 1' LANGUAGE sql"},
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, writeSchema(&buf, objects, nil))
+
+	schema := buf.String()
+	assert.True(t, strings.Index(schema, "CREATE FUNCTION next_id()") < strings.Index(schema, "CREATE TABLE users"))
+	assert.True(t, strings.Index(schema, "CREATE TABLE users") < strings.Index(schema, "CREATE VIEW admins"))
+	assert.True(t, strings.Index(schema, "CREATE VIEW admins") < strings.Index(schema, "CREATE VIEW active_admins"))
+}
+
+func TestDumpSchema(t *testing.T) {
+	if dbDriver != "sqlite3" {
+		t.Skip("the schema is dumped from sqlite_master only for SQLite3")
+	}
+
+	dbStore, err := databaseStoreFactory(dbDriver, db)
+	assert.Nil(t, err)
+
+	gl.Store = dbStore
+	defer func() { gl.Store = new(testingStore) }()
+
+	cleanState(func() {
+		_, err := gl.Mark(20170329154959)
+		assert.Nil(t, err)
+
+		_, err = db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT UNIQUE)`)
+		assert.Nil(t, err)
+
+		var buf bytes.Buffer
+		assert.Nil(t, gl.DumpSchema(&buf, NewSQLite3SchemaDumper(db)))
+
+		schema := buf.String()
+		assert.True(t, strings.Contains(schema, "CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT UNIQUE);\n"))
+		assert.True(t, strings.HasSuffix(schema, "-- gloat:applied 20170329154959 introduce_domain_model\n"))
+		assert.False(t, strings.Contains(schema, "schema_migrations"))
+		assert.False(t, strings.Contains(schema, "sqlite_autoindex"))
+	})
+}
+
+func TestPostgreSQLSchemaDumper(t *testing.T) {
+	if dbDriver != "postgres" {
+		t.Skip("the PostgreSQL schema dumper needs PostgreSQL")
+	}
+
+	dbStore, err := databaseStoreFactory(dbDriver, db)
+	assert.Nil(t, err)
+
+	gl.Store = dbStore
+	defer func() { gl.Store = new(testingStore) }()
+
+	dropObjects := func() {
+		db.Exec(`DROP VIEW IF EXISTS admins, zadmins CASCADE`)
+		db.Exec(`DROP TABLE IF EXISTS users CASCADE`)
+		db.Exec(`DROP FUNCTION IF EXISTS user_count()`)
+	}
+	defer dropObjects()
+
+	cleanState(func() {
+		dropObjects()
+
+		for _, statement := range []string{
+			`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT, domain TEXT GENERATED ALWAYS AS (split_part(email, '@', 2)) STORED)`,
+			`CREATE FUNCTION user_count() RETURNS BIGINT AS 'SELECT count(*) FROM users' LANGUAGE sql`,
+			`CREATE VIEW zadmins AS SELECT * FROM users`,
+			`CREATE VIEW admins AS SELECT * FROM zadmins`,
+		} {
+			_, err := db.Exec(statement)
+			assert.Nil(t, err)
+		}
+
+		var buf bytes.Buffer
+		assert.Nil(t, gl.DumpSchema(&buf, NewPostgreSQLSchemaDumper(db)))
+
+		schema := buf.String()
+		assert.True(t, strings.Contains(schema, `"domain" text GENERATED ALWAYS AS (split_part(email,`))
+		assert.False(t, strings.Contains(schema, "DEFAULT split_part"))
+		assert.True(t, strings.Index(schema, "FUNCTION public.user_count()") < strings.Index(schema, "CREATE TABLE"))
+		assert.True(t, strings.Index(schema, "CREATE VIEW zadmins") < strings.Index(schema, "CREATE VIEW admins"))
+
+		for _, statement := range []string{
+			`DROP VIEW admins, zadmins`,
+			`DROP FUNCTION user_count()`,
+			`DROP TABLE users`,
+			`DROP TABLE schema_migrations`,
+		} {
+			_, err := db.Exec(statement)
+			assert.Nil(t, err)
+		}
+
+		_, err := gl.LoadSchema(strings.NewReader(schema), db, PostgreSQL)
+		assert.Nil(t, err)
+	})
+}
+
+func TestMySQLTableObjects(t *testing.T) {
+	createTable := "CREATE TABLE `posts` (\n" +
+		"  `id` bigint NOT NULL AUTO_INCREMENT,\n" +
+		"  `user_id` bigint NOT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  CONSTRAINT `posts_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)\n" +
+		") ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8mb4"
+
+	objects := mysqlTableObjects("posts", createTable)
+
+	assert.Len(t, 2, objects)
+	assert.Equal(t, "CREATE TABLE `posts` (\n"+
+		"  `id` bigint NOT NULL AUTO_INCREMENT,\n"+
+		"  `user_id` bigint NOT NULL,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4", objects[0].SQL)
+	assert.Equal(t, "foreign key", objects[1].Type)
+	assert.Equal(t, "posts.posts_user_id", objects[1].Name)
+	assert.Equal(t, "ALTER TABLE `posts` ADD CONSTRAINT `posts_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)", objects[1].SQL)
+}
+
+func TestLoadSchema(t *testing.T) {
+	dbStore, err := databaseStoreFactory(dbDriver, db)
+	assert.Nil(t, err)
+
+	gl.Store = dbStore
+	defer func() { gl.Store = new(testingStore) }()
+
+	schema := `-- The structure of the database, dumped by gloat.
+
+CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT);
+
+-- gloat:applied 20170329154959 introduce_domain_model
+-- gloat:applied 20170511172647 irreversible_migration_brah
+-- gloat:applied 20160101000000 squashed_away
+`
+
+	cleanState(func() {
+		loaded, err := gl.LoadSchema(strings.NewReader(schema), db, Dialect(dbDriver))
+		assert.Nil(t, err)
+		assert.Len(t, 3, loaded)
+
+		_, err = db.Exec(`INSERT INTO users (id, email) VALUES (1, 'gloat@example.com')`)
+		assert.Nil(t, err)
+
+		migrations, err := dbStore.Collect()
+		assert.Nil(t, err)
+		migrations.Sort()
+
+		assert.Len(t, 3, migrations)
+		assert.Equal(t, 20160101000000, migrations[0].Version)
+		assert.Equal(t, "squashed_away", migrations[0].Name())
+		assert.Equal(t, 20170329154959, migrations[1].Version)
+		assert.NotEqual(t, "", migrations[1].Checksum)
+
+		_, err = gl.LoadSchema(strings.NewReader(schema), db, Dialect(dbDriver))
+		assert.Error(t, err)
+	})
+}
+
+func TestParseSchemaApplied(t *testing.T) {
+	migrations, err := parseSchemaApplied([]byte("CREATE TABLE users ();\n-- gloat:applied 42\n-- gloat:applied 43 add_posts\n"))
+	assert.Nil(t, err)
+
+	assert.Len(t, 2, migrations)
+	assert.Equal(t, 42, migrations[0].Version)
+	assert.Equal(t, "", migrations[0].Name())
+	assert.Equal(t, "add_posts", migrations[1].Name())
+
+	_, err = parseSchemaApplied([]byte("-- gloat:applied latest\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,154 @@
+package gloat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Script writes a standalone SQL script that brings a database from one
+// version to another, for the cases when the migrations are executed by hand.
+// The from version is the last migration applied to the database, 0 for an
+// empty one. If to is greater than from, the migrations in between are
+// applied oldest first. Otherwise, they are reverted newest first. Use Latest
+// to apply every migration after from.
+//
+// The script creates the store table, if needed, upgrades the tables of older
+// gloat versions where the dialect allows it, and records every migration in
+// it, exactly like running the migrations through gloat. Migrations with
+// the Transaction option are wrapped in BEGIN and COMMIT. Migrations written
+// in Go cannot be scripted and fail the whole script.
+func (m Migrations) Script(w io.Writer, from, to int64, store StatementStore) error {
+	plan, err := m.scriptPlan(from, to)
+	if err != nil {
+		return err
+	}
+
+	return writeScript(w, from, to, plan, store)
+}
+
+func writeScript(w io.Writer, from, to int64, plan Plan, store StatementStore) error {
+	fmt.Fprintf(w, "-- Generated by gloat %s, migrating from %s to %s.\n\n", Version, scriptVersion(from), scriptVersion(to))
+
+	for _, statement := range store.CreateStatements() {
+		fmt.Fprintf(w, "%s;\n", statement)
+	}
+	fmt.Fprintf(w, "\n")
+
+	for _, step := range plan {
+		bookkeeping := store.InsertStatement(step.Migration)
+		if step.Direction == Down {
+			bookkeeping = store.RemoveStatement(step.Migration)
+		}
+
+		if err := writeMigration(w, step.Migration, step.Direction, bookkeeping); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m Migrations) scriptPlan(from, to int64) (Plan, error) {
+	for _, version := range []int64{from, to} {
+		if version != 0 && version != Latest && !m.Has(version) {
+			return nil, fmt.Errorf("cannot script unknown version %d", version)
+		}
+	}
+
+	migrations := make(Migrations, len(m))
+	copy(migrations, m)
+	sort.Sort(migrations)
+
+	var plan Plan
+
+	if to >= from {
+		for _, migration := range migrations {
+			if migration.Version > from && migration.Version <= to {
+				plan = append(plan, Step{Migration: migration, Direction: Up})
+			}
+		}
+	} else {
+		for i := len(migrations) - 1; i >= 0; i-- {
+			migration := migrations[i]
+			if migration.Version > to && migration.Version <= from {
+				if !migration.Reversible() {
+					return nil, IrreversibleError{migration.Version}
+				}
+
+				plan = append(plan, Step{Migration: migration, Direction: Down})
+			}
+		}
+	}
+
+	for _, step := range plan {
+		if step.Migration.UpFunc != nil || step.Migration.DownFunc != nil {
+			return nil, fmt.Errorf("migration %d is written in Go and cannot be scripted", step.Migration.Version)
+		}
+	}
+
+	return plan, nil
+}
+
+// Script writes a standalone SQL script migrating the source migrations from
+// one version to another. The Store has to be a StatementStore, like the
+// builtin database stores. It is used only to render the statements, the
+// database is never queried. See Migrations.Script for the details.
+func (c *Gloat) Script(w io.Writer, from, to int64) error {
+	return c.ScriptContext(context.Background(), w, from, to)
+}
+
+// ScriptContext writes a standalone SQL script migrating the source
+// migrations from one version to another. See Script for the details.
+func (c *Gloat) ScriptContext(ctx context.Context, w io.Writer, from, to int64) error {
+	store, ok := c.Store.(StatementStore)
+	if !ok {
+		return errors.New("the store cannot render SQL statements")
+	}
+
+	migrations, err := collect(ctx, c.Source)
+	if err != nil {
+		return err
+	}
+
+	plan, err := migrations.scriptPlan(from, to)
+	if err != nil {
+		return err
+	}
+
+	// Only the planned side of the planned migrations is rendered, so the
+	// variables of the others are not needed.
+	for i, step := range plan {
+		step.Migration.Checksum = c.Checksum.Sum(step.Migration.UpSQL)
+
+		if plan[i].Migration, err = step.Migration.Render(c.Vars, step.Direction); err != nil {
+			return err
+		}
+	}
+
+	return writeScript(w, from, to, plan, store)
+}
+
+func scriptVersion(version int64) string {
+	switch version {
+	case 0:
+		return "an empty database"
+	case Latest:
+		return "the latest version"
+	default:
+		return fmt.Sprintf("version %d", version)
+	}
+}
+
+// dedent strips the indentation the builtin statements are declared with.
+func dedent(statement string) string {
+	lines := strings.Split(strings.TrimSpace(statement), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, "\t\t\t")
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,132 @@
+package gloat
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func TestMigrationsScript(t *testing.T) {
+	migrations := Migrations{
+		&Migration{
+			UpSQL:   []byte("ALTER TABLE users ADD COLUMN token VARCHAR(255)"),
+			DownSQL: []byte("ALTER TABLE users DROP COLUMN token;"),
+			Path:    "20180905150724_add_users_token",
+			Version: 20180905150724,
+			Options: MigrationOptions{Transaction: false},
+		},
+		&Migration{
+			UpSQL:   []byte("CREATE TABLE users (id INTEGER);\n"),
+			DownSQL: []byte("DROP TABLE users;\n"),
+			Path:    "20170329154959_introduce_domain_model",
+			Version: 20170329154959,
+			Options: DefaultMigrationOptions(),
+		},
+	}
+
+	store := newDatabaseStore(nil, SQLite3, nil)
+
+	var script bytes.Buffer
+
+	err := migrations.Script(&script, 0, Latest, store)
+	assert.Nil(t, err)
+
+	content := script.String()
+	assert.True(t, strings.Contains(content, "CREATE TABLE IF NOT EXISTS \"schema_migrations\""))
+	assert.True(t, strings.Index(content, "CREATE TABLE users") < strings.Index(content, "ADD COLUMN token"))
+	assert.True(t, strings.Contains(content, "BEGIN;\nCREATE TABLE users (id INTEGER);\nINSERT INTO"))
+	assert.True(t, strings.Contains(content, "ADD COLUMN token VARCHAR(255);\nINSERT INTO"))
+
+	script.Reset()
+
+	err = migrations.Script(&script, 20180905150724, 20170329154959, store)
+	assert.Nil(t, err)
+
+	content = script.String()
+	assert.True(t, strings.Contains(content, "ALTER TABLE users DROP COLUMN token;\nDELETE FROM \"schema_migrations\" WHERE version=20180905150724;"))
+	assert.False(t, strings.Contains(content, "DROP TABLE users"))
+}
+
+func TestMigrationsScript_Errors(t *testing.T) {
+	migrations := Migrations{
+		&Migration{UpSQL: []byte("SELECT 1;"), Version: 20170329154959},
+		&Migration{Version: 20180905150724, UpFunc: func(context.Context, SQLExecer) error { return nil }},
+	}
+
+	store := newDatabaseStore(nil, SQLite3, nil)
+
+	var script bytes.Buffer
+
+	assert.Error(t, migrations.Script(&script, 0, 42, store))
+	assert.Equal(t, IrreversibleError{20170329154959}, migrations.Script(&script, 20170329154959, 0, store))
+	assert.Error(t, migrations.Script(&script, 0, Latest, store))
+	assert.Equal(t, 0, script.Len())
+}
+
+func TestScript_Executes(t *testing.T) {
+	if dbDriver != "sqlite3" {
+		t.Skip("the script is executed in one go only on SQLite3")
+	}
+
+	gl.Store = newDatabaseStore(db, SQLite3, nil)
+	defer func() { gl.Store = new(testingStore) }()
+
+	var script bytes.Buffer
+
+	err := gl.Script(&script, 0, 20170329154959)
+	assert.Nil(t, err)
+
+	cleanState(func() {
+		_, err := db.Exec(script.String())
+		assert.Nil(t, err)
+
+		migrations, err := gl.Store.Collect()
+		assert.Nil(t, err)
+
+		assert.Len(t, 1, migrations)
+		assert.Equal(t, 20170329154959, migrations[0].Version)
+
+		sourceMigrations, err := gl.Source.Collect()
+		assert.Nil(t, err)
+		assert.Equal(t, checksum(sourceMigrations.Find(20170329154959).UpSQL), migrations[0].Checksum)
+
+		_, err = db.Exec(`SELECT id FROM users LIMIT 1`)
+		assert.Nil(t, err)
+	})
+}
+
+func TestScript_RendersPlannedMigrations(t *testing.T) {
+	migrations := Migrations{
+		&Migration{
+			UpSQL:   []byte("CREATE SCHEMA {{.schema}};"),
+			DownSQL: []byte("ALTER SCHEMA {{.schema}} RENAME TO {{.old_schema}};"),
+			Path:    "20170329154959_create_schema",
+			Version: 20170329154959,
+			Options: MigrationOptions{Transaction: true, Split: true, Template: true},
+		},
+		&Migration{
+			UpSQL:   []byte("GRANT USAGE ON SCHEMA {{.schema}} TO {{.role}};"),
+			Path:    "20180905150724_grant_usage",
+			Version: 20180905150724,
+			Options: MigrationOptions{Transaction: true, Split: true, Template: true},
+		},
+	}
+
+	prevSource := gl.Source
+	defer func() { gl.Source = prevSource }()
+
+	gl.Source = &testingStore{applied: migrations}
+	gl.Store = newDatabaseStore(nil, SQLite3, nil)
+	defer func() { gl.Store = new(testingStore) }()
+	gl.Vars = Vars{"schema": "tenant"}
+	defer func() { gl.Vars = nil }()
+
+	var script bytes.Buffer
+
+	err := gl.Script(&script, 0, 20170329154959)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(script.String(), "CREATE SCHEMA tenant;"))
+}
@@ -1,8 +1,12 @@
 package gloat
 
 import (
+	"context"
+	"errors"
+	"io/fs"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 )
 
@@ -11,46 +15,92 @@ type Source interface {
 	Collect() (Migrations, error)
 }
 
+// ContextSource is a Source that can be cancelled through a context.
+type ContextSource interface {
+	Source
+
+	CollectContext(context.Context) (Migrations, error)
+}
+
 // FileSystemSource is a file system source of migrations. The migrations are
 // stored in folders with the following structure:
 //
 // migrations/
-// └── 20170329154959_introduce_domain_model
-//     ├── down.sql
-//     └── up.sql
+// ├── 20170329154959_introduce_domain_model
+// │   ├── down.sql
+// │   └── up.sql
+// └── 20180905150724_add_users.sql
+//
+// Single-file migrations, like 20180905150724_add_users.sql, can live next
+// to the folders. See MigrationFromFile for their format.
+//
+// Every problem with the migrations, like duplicated versions, names without
+// a version or folders without an up.sql, is reported in one SourceError.
 type FileSystemSource struct {
 	Dir string
+
+	// SkipUnknown skips the hidden entries and the entries without a version
+	// in their name, instead of reporting them.
+	SkipUnknown bool
 }
 
 // Collect builds migrations stored in a folder like the following structure:
 //
 // migrations/
-// └── 20170329154959_introduce_domain_model
-//     ├── down.sql
-//     └── up.sql
-func (s *FileSystemSource) Collect() (migrations Migrations, err error) {
-	err = filepath.Walk(s.Dir, func(path string, info os.FileInfo, err error) error {
-		if info != nil && info.IsDir() && path != s.Dir {
-			migration, err := MigrationFromBytes(path, ioutil.ReadFile)
-			if err != nil {
-				return err
-			}
-
-			migrations = append(migrations, migration)
+// ├── 20170329154959_introduce_domain_model
+// │   ├── down.sql
+// │   └── up.sql
+// └── 20180905150724_add_users.sql
+func (s *FileSystemSource) Collect() (Migrations, error) {
+	return s.CollectContext(context.Background())
+}
+
+// CollectContext builds migrations stored in a folder. See Collect for the
+// expected structure.
+func (s *FileSystemSource) CollectContext(ctx context.Context) (migrations Migrations, err error) {
+	entries, err := ioutil.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+
+	collector := sourceCollector{skipUnknown: s.SkipUnknown}
+
+	for _, entry := range entries {
+		if err = ctx.Err(); err != nil {
+			return
 		}
 
-		return nil
-	})
+		entryPath := filepath.Join(s.Dir, entry.Name())
 
-	migrations.Sort()
+		switch {
+		case entry.IsDir():
+			collector.add(entryPath, true, func() bool {
+				_, err := os.Stat(filepath.Join(entryPath, "up.sql"))
+				return !os.IsNotExist(err)
+			}, func() (*Migration, error) {
+				return MigrationFromBytes(entryPath, ioutil.ReadFile)
+			})
+		case isMigrationFile(entry.Name()):
+			collector.add(entryPath, false, nil, func() (*Migration, error) {
+				return MigrationFromFile(entryPath, ioutil.ReadFile)
+			})
+		default:
+			collector.add(entryPath, false, nil, nil)
+		}
+	}
 
-	return
+	return collector.result()
 }
 
 // NewFileSystemSource creates a new source of migrations that takes them right
 // out of the file system.
-func NewFileSystemSource(dir string) Source {
-	return &FileSystemSource{Dir: dir}
+func NewFileSystemSource(dir string, options ...SourceOption) Source {
+	o := newSourceOptions(options)
+
+	return &FileSystemSource{Dir: dir, SkipUnknown: o.skipUnknown}
 }
 
 // AssetSource is a go-bindata migration source for binary embedded migrations.
@@ -60,33 +110,175 @@ type AssetSource struct {
 	Prefix   string
 	Asset    func(string) ([]byte, error)
 	AssetDir func(string) ([]string, error)
+
+	// SkipUnknown skips the hidden entries and the entries without a version
+	// in their name, instead of reporting them.
+	SkipUnknown bool
 }
 
 // Collect builds migrations from a go-bindata embedded migrations.
-func (s *AssetSource) Collect() (migrations Migrations, err error) {
+func (s *AssetSource) Collect() (Migrations, error) {
+	return s.CollectContext(context.Background())
+}
+
+// CollectContext builds migrations from a go-bindata embedded migrations.
+func (s *AssetSource) CollectContext(ctx context.Context) (migrations Migrations, err error) {
 	dirs, err := s.AssetDir(s.Prefix)
 	if err != nil {
 		return
 	}
 
-	for _, path := range dirs {
-		var migration *Migration
+	collector := sourceCollector{skipUnknown: s.SkipUnknown}
 
-		migration, err = MigrationFromBytes(filepath.Join(s.Prefix, path), s.Asset)
-		if err != nil {
+	for _, path := range dirs {
+		if err = ctx.Err(); err != nil {
 			return
 		}
 
-		migrations = append(migrations, migration)
-	}
+		assetPath := filepath.Join(s.Prefix, path)
+
+		if isMigrationFile(path) {
+			collector.add(assetPath, false, nil, func() (*Migration, error) {
+				return MigrationFromFile(assetPath, s.Asset)
+			})
+		} else {
+			collector.add(assetPath, true, func() bool {
+				files, _ := s.AssetDir(assetPath)
+				for _, file := range files {
+					if file == "up.sql" {
+						return true
+					}
+				}
 
-	migrations.Sort()
+				return false
+			}, func() (*Migration, error) {
+				return MigrationFromBytes(assetPath, s.Asset)
+			})
+		}
+	}
 
-	return
+	return collector.result()
 }
 
 // NewAssetSource creates a new source of binary migrations embedded into the
 // program with go-bindata.
-func NewAssetSource(prefix string, asset func(string) ([]byte, error), assetDir func(string) ([]string, error)) Source {
-	return &AssetSource{Prefix: prefix, Asset: asset, AssetDir: assetDir}
+func NewAssetSource(prefix string, asset func(string) ([]byte, error), assetDir func(string) ([]string, error), options ...SourceOption) Source {
+	o := newSourceOptions(options)
+
+	return &AssetSource{Prefix: prefix, Asset: asset, AssetDir: assetDir, SkipUnknown: o.skipUnknown}
+}
+
+// FSSource is a source of migrations stored in an fs.FS. It can read
+// migrations embedded with go:embed, as well as migrations in os.DirFS or the
+// in-memory fstest.MapFS. The migrations are expected in folders or single
+// files under Dir, following the same structure as with FileSystemSource.
+type FSSource struct {
+	FS  fs.FS
+	Dir string
+
+	// SkipUnknown skips the hidden entries and the entries without a version
+	// in their name, instead of reporting them.
+	SkipUnknown bool
+}
+
+// Collect builds migrations stored in a folder of an fs.FS.
+func (s *FSSource) Collect() (Migrations, error) {
+	return s.CollectContext(context.Background())
+}
+
+// CollectContext builds migrations stored in a folder of an fs.FS.
+func (s *FSSource) CollectContext(ctx context.Context) (migrations Migrations, err error) {
+	entries, err := fs.ReadDir(s.FS, s.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+
+	// The fs.FS paths are always slash separated, while MigrationFromBytes
+	// joins the migration files with the OS separator.
+	read := func(name string) ([]byte, error) {
+		return fs.ReadFile(s.FS, filepath.ToSlash(name))
+	}
+
+	collector := sourceCollector{skipUnknown: s.SkipUnknown}
+
+	for _, entry := range entries {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		entryPath := path.Join(s.Dir, entry.Name())
+
+		switch {
+		case entry.IsDir():
+			collector.add(entryPath, true, func() bool {
+				_, err := fs.Stat(s.FS, path.Join(entryPath, "up.sql"))
+				return !errors.Is(err, fs.ErrNotExist)
+			}, func() (*Migration, error) {
+				return MigrationFromBytes(entryPath, read)
+			})
+		case isMigrationFile(entry.Name()):
+			collector.add(entryPath, false, nil, func() (*Migration, error) {
+				return MigrationFromFile(entryPath, read)
+			})
+		default:
+			collector.add(entryPath, false, nil, nil)
+		}
+	}
+
+	return collector.result()
+}
+
+// NewFSSource creates a new source of migrations that takes them out of a
+// folder in an fs.FS. Use "." as the folder for the root of the fs.FS.
+func NewFSSource(fsys fs.FS, dir string, options ...SourceOption) Source {
+	o := newSourceOptions(options)
+
+	return &FSSource{FS: fsys, Dir: dir, SkipUnknown: o.skipUnknown}
+}
+
+// MultiSource merges the migrations of several sources into one timeline, e.g.
+// SQL migrations from the file system and migrations written in Go.
+type MultiSource struct {
+	Sources []Source
+}
+
+// Collect builds the migrations of every source, ordered by version. It is an
+// error for two migrations to share a version, they are reported in a
+// SourceError.
+func (s *MultiSource) Collect() (Migrations, error) {
+	return s.CollectContext(context.Background())
+}
+
+// CollectContext builds the migrations of every source. See Collect for the
+// details.
+func (s *MultiSource) CollectContext(ctx context.Context) (Migrations, error) {
+	var collector sourceCollector
+
+	for _, source := range s.Sources {
+		sourceMigrations, err := collect(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+
+		collector.migrations = append(collector.migrations, sourceMigrations...)
+	}
+
+	return collector.result()
+}
+
+// NewMultiSource creates a source that merges the migrations of the given
+// sources.
+func NewMultiSource(sources ...Source) Source {
+	return &MultiSource{Sources: sources}
+}
+
+func collect(ctx context.Context, source Source) (Migrations, error) {
+	if source, ok := source.(ContextSource); ok {
+		return source.CollectContext(ctx)
+	}
+
+	return source.Collect()
 }
@@ -1,9 +1,12 @@
 package gloat
 
 import (
+	"errors"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
+	"testing/fstest"
 
 	"github.com/gsamokovarov/assert"
 )
@@ -50,3 +53,115 @@ func TestAssetSourceDoesNotBreakOnIrreversibleMigrations(t *testing.T) {
 
 	assert.Len(t, 2, migrations)
 }
+
+func TestFSSourceCollect(t *testing.T) {
+	fs := NewFSSource(os.DirFS("testdata"), "migrations")
+
+	migrations, err := fs.Collect()
+	assert.Nil(t, err)
+
+	assert.Len(t, 4, migrations)
+
+	m1, err := MigrationFromBytes("testdata/migrations/20170329154959_introduce_domain_model", ioutil.ReadFile)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "migrations/20170329154959_introduce_domain_model", migrations[0].Path)
+	assert.Equal(t, m1.UpSQL, migrations[0].UpSQL)
+	assert.Equal(t, m1.DownSQL, migrations[0].DownSQL)
+	assert.False(t, migrations[2].Options.Transaction)
+}
+
+func TestFSSourceCollectInMemory(t *testing.T) {
+	fs := NewFSSource(fstest.MapFS{
+		"20180905150724_add_users/up.sql":    {Data: []byte("CREATE TABLE users (id bigint);")},
+		"20180905150724_add_users/down.sql":  {Data: []byte("DROP TABLE users;")},
+		"20170329154959_irreversible/up.sql": {Data: []byte("SELECT 1;")},
+		"README.md":                          {Data: []byte("Migrations")},
+	}, ".", WithSkipUnknown())
+
+	migrations, err := fs.Collect()
+	assert.Nil(t, err)
+
+	assert.Len(t, 2, migrations)
+
+	assert.Equal(t, 20170329154959, migrations[0].Version)
+	assert.False(t, migrations[0].Reversible())
+
+	assert.Equal(t, 20180905150724, migrations[1].Version)
+	assert.Equal(t, "add_users", migrations[1].Name())
+	assert.Equal(t, []byte("DROP TABLE users;"), migrations[1].DownSQL)
+}
+
+func TestFSSourceCollectEmpty(t *testing.T) {
+	fs := NewFSSource(os.DirFS("testdata"), "no_migrations")
+
+	migrations, err := fs.Collect()
+	assert.Nil(t, err)
+
+	assert.Len(t, 0, migrations)
+}
+
+func TestFileSystemSourceCollectMixed(t *testing.T) {
+	fs := NewFileSystemSource("testdata/mixed_migrations")
+
+	migrations, err := fs.Collect()
+	assert.Nil(t, err)
+
+	assert.Len(t, 2, migrations)
+
+	assert.Equal(t, "introduce_domain_model", migrations[0].Name())
+	assert.Equal(t, "add_users_token", migrations[1].Name())
+	assert.True(t, migrations[1].Reversible())
+}
+
+func TestFSSourceCollectInvalid(t *testing.T) {
+	fsys := fstest.MapFS{
+		"20180905150724_add_users/up.sql":      {Data: []byte("CREATE TABLE users (id bigint);")},
+		"20180905150724_add_users_token.sql":   {Data: []byte("-- gloat:up\nALTER TABLE users ADD token text;")},
+		"20170329154959_introduce/down.sql":    {Data: []byte("DROP TABLE users;")},
+		"README/index.md":                      {Data: []byte("Migrations")},
+		".git/HEAD":                            {Data: []byte("ref: refs/heads/master")},
+		".keep":                                {Data: []byte("")},
+		"20170511172647_notes.txt":             {Data: []byte("Notes")},
+		"20170511172647_irreversible/up.sql":   {Data: []byte("SELECT 1;")},
+		"20170511172647_irreversible/down.sql": {Data: []byte("")},
+	}
+
+	_, err := NewFSSource(fsys, ".").Collect()
+
+	var sourceErr SourceError
+	assert.True(t, errors.As(err, &sourceErr))
+	assert.Len(t, 6, sourceErr.Errors)
+	assert.Equal(t, InvalidVersionError{".git"}, sourceErr.Errors[0])
+	assert.Equal(t, InvalidVersionError{".keep"}, sourceErr.Errors[1])
+	assert.Equal(t, MissingUpError{"20170329154959_introduce"}, sourceErr.Errors[2])
+	assert.Equal(t, UnknownEntryError{"20170511172647_notes.txt"}, sourceErr.Errors[3])
+	assert.Equal(t, InvalidVersionError{"README"}, sourceErr.Errors[4])
+	assert.Equal(t, DuplicateVersionError{
+		Version: 20180905150724,
+		Paths:   []string{"20180905150724_add_users", "20180905150724_add_users_token.sql"},
+	}, sourceErr.Errors[5])
+
+	_, err = NewFSSource(fsys, ".", WithSkipUnknown()).Collect()
+	assert.True(t, errors.As(err, &sourceErr))
+	assert.Len(t, 2, sourceErr.Errors)
+
+	delete(fsys, "20180905150724_add_users_token.sql")
+	delete(fsys, "20170329154959_introduce/down.sql")
+
+	migrations, err := NewFSSource(fsys, ".", WithSkipUnknown()).Collect()
+	assert.Nil(t, err)
+	assert.Len(t, 2, migrations)
+}
+
+func TestVersionFromPath(t *testing.T) {
+	version, err := versionFromPath("migrations/20170329154959_introduce_domain_model")
+	assert.Nil(t, err)
+	assert.Equal(t, 20170329154959, version)
+
+	_, err = versionFromPath("migrations/README")
+	assert.Equal(t, InvalidVersionError{"migrations/README"}, err)
+
+	_, err = versionFromPath("migrations/-1_negative")
+	assert.Error(t, err)
+}
@@ -0,0 +1,170 @@
+package gloat
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SourceError lists every problem found in the migrations of a Source, so
+// they can be fixed at once.
+type SourceError struct {
+	Errors []error
+}
+
+// Error implements the error interface.
+func (err SourceError) Error() string {
+	messages := make([]string, len(err.Errors))
+	for i, err := range err.Errors {
+		messages[i] = err.Error()
+	}
+
+	return fmt.Sprintf("invalid migrations: %s", strings.Join(messages, "; "))
+}
+
+// DuplicateVersionError is the error returned when more than one migration
+// has the same version.
+type DuplicateVersionError struct {
+	Version int64
+	Paths   []string
+}
+
+// Error implements the error interface.
+func (err DuplicateVersionError) Error() string {
+	return fmt.Sprintf("version %d is used by %s", err.Version, strings.Join(err.Paths, ", "))
+}
+
+// InvalidVersionError is the error returned when the name of a migration does
+// not start with a version, like README or .git.
+type InvalidVersionError struct {
+	Path string
+}
+
+// Error implements the error interface.
+func (err InvalidVersionError) Error() string {
+	return fmt.Sprintf("cannot extract version from %s", err.Path)
+}
+
+// UnknownEntryError is the error returned for the entries of a source that
+// start with a version, but are neither folders nor .sql files.
+type UnknownEntryError struct {
+	Path string
+}
+
+// Error implements the error interface.
+func (err UnknownEntryError) Error() string {
+	return fmt.Sprintf("%s is neither a migration folder nor a %s file", err.Path, MigrationFileExt)
+}
+
+// MissingUpError is the error returned when a migration folder has no up.sql.
+type MissingUpError struct {
+	Path string
+}
+
+// Error implements the error interface.
+func (err MissingUpError) Error() string {
+	return fmt.Sprintf("%s has no up.sql", err.Path)
+}
+
+// SourceOption configures the builtin sources of SQL migrations.
+type SourceOption func(*sourceOptions)
+
+type sourceOptions struct {
+	skipUnknown bool
+}
+
+// WithSkipUnknown makes a source skip the hidden entries, like .git, the
+// entries whose names do not start with a version, like README, and the files
+// that are not migrations, instead of failing with an InvalidVersionError or
+// an UnknownEntryError.
+func WithSkipUnknown() SourceOption {
+	return func(o *sourceOptions) { o.skipUnknown = true }
+}
+
+func newSourceOptions(options []SourceOption) sourceOptions {
+	var o sourceOptions
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return o
+}
+
+// sourceCollector gathers the migrations of a source, along with every
+// problem found in them.
+type sourceCollector struct {
+	skipUnknown bool
+	migrations  Migrations
+	errs        []error
+}
+
+// add loads an entry of a source. Folders that have no up.sql are reported
+// as missing it, instead of failing on the read. Entries without a load
+// function are not migrations and are reported as unknown.
+func (c *sourceCollector) add(path string, isDir bool, hasUp func() bool, load func() (*Migration, error)) {
+	name := filepath.Base(path)
+
+	if c.skipUnknown && strings.HasPrefix(name, ".") {
+		return
+	}
+
+	if _, err := versionFromPath(path); err != nil {
+		if !c.skipUnknown {
+			c.errs = append(c.errs, err)
+		}
+
+		return
+	}
+
+	if load == nil {
+		if !c.skipUnknown {
+			c.errs = append(c.errs, UnknownEntryError{path})
+		}
+
+		return
+	}
+
+	if isDir && !hasUp() {
+		c.errs = append(c.errs, MissingUpError{path})
+		return
+	}
+
+	migration, err := load()
+	if err != nil {
+		c.errs = append(c.errs, err)
+		return
+	}
+
+	c.migrations = append(c.migrations, migration)
+}
+
+// result returns the sorted migrations or a SourceError with the problems
+// found in them, including the duplicated versions.
+func (c *sourceCollector) result() (Migrations, error) {
+	paths := make(map[int64][]string)
+	for _, migration := range c.migrations {
+		paths[migration.Version] = append(paths[migration.Version], migration.Path)
+	}
+
+	var duplicates []int64
+	for version, versionPaths := range paths {
+		if len(versionPaths) > 1 {
+			duplicates = append(duplicates, version)
+		}
+	}
+	sort.Slice(duplicates, func(i, j int) bool { return duplicates[i] < duplicates[j] })
+
+	for _, version := range duplicates {
+		c.errs = append(c.errs, DuplicateVersionError{Version: version, Paths: paths[version]})
+	}
+
+	if len(c.errs) > 0 {
+		return nil, SourceError{c.errs}
+	}
+
+	c.migrations.Sort()
+
+	return c.migrations, nil
+}
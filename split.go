@@ -0,0 +1,322 @@
+package gloat
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Statement is a single SQL statement out of a migration.
+type Statement struct {
+	// SQL is the statement text, without its delimiter.
+	SQL string
+
+	// Offset is the byte offset of the statement in the migration content.
+	Offset int
+}
+
+// SplitStatements splits the content of a migration into statements. The
+// splitter understands string literals, quoted identifiers and comments, so a
+// semicolon inside them does not end a statement. On top of that, it knows
+// about the following dialect specifics:
+//
+//   - PostgreSQL $$ and $tag$ dollar quoting and nested block comments.
+//   - PostgreSQL BEGIN ATOMIC ... END function bodies.
+//   - MySQL DELIMITER commands, backslash escapes, backtick quoting and # comments.
+//   - SQLite3 CREATE TRIGGER ... BEGIN ... END bodies.
+func SplitStatements(content []byte, dialect Dialect) ([]Statement, error) {
+	s := &splitter{content: content, dialect: dialect, delimiter: []byte(";")}
+
+	if err := s.split(); err != nil {
+		return nil, err
+	}
+
+	return s.statements, nil
+}
+
+type splitter struct {
+	content   []byte
+	dialect   Dialect
+	delimiter []byte
+
+	statements []Statement
+
+	start   int
+	hasCode bool
+	words   []string
+	block   bool
+	depth   int
+}
+
+func (s *splitter) split() error {
+	for i := 0; i < len(s.content); {
+		if s.dialect == MySQL && !s.hasCode && atLineStart(s.content, i) && hasWordPrefix(s.content[i:], "DELIMITER") {
+			end := lineEnd(s.content, i)
+
+			delimiter := bytes.TrimSpace(s.content[i+len("DELIMITER") : end])
+			if len(delimiter) == 0 {
+				return s.errorf(i, "DELIMITER without a delimiter")
+			}
+
+			s.delimiter = delimiter
+			i = end
+			s.reset(i)
+
+			continue
+		}
+
+		if s.depth == 0 && bytes.HasPrefix(s.content[i:], s.delimiter) {
+			s.emit(i)
+			i += len(s.delimiter)
+			s.reset(i)
+
+			continue
+		}
+
+		var err error
+
+		c := s.content[i]
+
+		switch {
+		case c == '-' && s.peek(i+1) == '-', c == '#' && s.dialect == MySQL:
+			i = lineEnd(s.content, i)
+		case c == '/' && s.peek(i+1) == '*':
+			i, err = s.skipBlockComment(i)
+		case c == '\'':
+			i, err = s.skipQuoted(i, '\'', s.backslashEscapes(i))
+			s.hasCode = true
+		case c == '"':
+			i, err = s.skipQuoted(i, '"', s.dialect == MySQL)
+			s.hasCode = true
+		case c == '`' && s.dialect == MySQL:
+			i, err = s.skipQuoted(i, '`', false)
+			s.hasCode = true
+		case c == '$' && s.dialect == PostgreSQL && !isWordChar(s.peek(i-1)):
+			i, err = s.skipDollarQuoted(i)
+			s.hasCode = true
+		case isWordStart(c):
+			i = s.word(i)
+		default:
+			if !isSpace(c) {
+				s.hasCode = true
+			}
+			i++
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.depth != 0 {
+		return s.errorf(s.start, "unterminated BEGIN ... END block")
+	}
+
+	s.emit(len(s.content))
+
+	return nil
+}
+
+func (s *splitter) emit(end int) {
+	if !s.hasCode {
+		return
+	}
+
+	text := s.content[s.start:end]
+	trimmed := bytes.TrimLeft(text, " \t\r\n")
+
+	s.statements = append(s.statements, Statement{
+		SQL:    string(bytes.TrimRight(trimmed, " \t\r\n")),
+		Offset: s.start + len(text) - len(trimmed),
+	})
+}
+
+func (s *splitter) reset(start int) {
+	s.start = start
+	s.hasCode = false
+	s.words = nil
+	s.block = false
+	s.depth = 0
+}
+
+// word consumes a keyword or an identifier. The first words of a statement
+// decide whether it has a BEGIN ... END body, which can contain semicolons.
+func (s *splitter) word(i int) int {
+	end := i
+	for end < len(s.content) && isWordChar(s.content[end]) {
+		end++
+	}
+
+	word := strings.ToUpper(string(s.content[i:end]))
+
+	s.hasCode = true
+	if len(s.words) < 4 {
+		s.words = append(s.words, word)
+		s.block = s.block || s.isBlockStatement()
+	}
+
+	if s.block {
+		switch word {
+		case "BEGIN", "CASE":
+			s.depth++
+		case "END":
+			if s.depth > 0 {
+				s.depth--
+			}
+		}
+	}
+
+	return end
+}
+
+func (s *splitter) isBlockStatement() bool {
+	statement := strings.Join(s.words, " ")
+
+	switch s.dialect {
+	case SQLite3:
+		return strings.HasPrefix(statement, "CREATE TRIGGER") ||
+			strings.HasPrefix(statement, "CREATE TEMP TRIGGER") ||
+			strings.HasPrefix(statement, "CREATE TEMPORARY TRIGGER")
+	case PostgreSQL:
+		return strings.HasPrefix(statement, "CREATE FUNCTION") ||
+			strings.HasPrefix(statement, "CREATE PROCEDURE") ||
+			strings.HasPrefix(statement, "CREATE OR REPLACE FUNCTION") ||
+			strings.HasPrefix(statement, "CREATE OR REPLACE PROCEDURE")
+	}
+
+	return false
+}
+
+func (s *splitter) skipBlockComment(i int) (int, error) {
+	start := i
+	depth := 0
+
+	for i < len(s.content) {
+		switch {
+		case s.content[i] == '/' && s.peek(i+1) == '*':
+			depth++
+			i += 2
+		case s.content[i] == '*' && s.peek(i+1) == '/':
+			depth--
+			i += 2
+
+			// Only PostgreSQL nests block comments.
+			if depth == 0 || s.dialect != PostgreSQL {
+				return i, nil
+			}
+		default:
+			i++
+		}
+	}
+
+	return i, s.errorf(start, "unterminated block comment")
+}
+
+func (s *splitter) skipQuoted(i int, quote byte, backslashEscapes bool) (int, error) {
+	start := i
+
+	for i++; i < len(s.content); i++ {
+		switch s.content[i] {
+		case '\\':
+			if backslashEscapes {
+				i++
+			}
+		case quote:
+			if s.peek(i+1) != quote {
+				return i + 1, nil
+			}
+			i++
+		}
+	}
+
+	return i, s.errorf(start, "unterminated %c quote", quote)
+}
+
+func (s *splitter) skipDollarQuoted(i int) (int, error) {
+	end := i + 1
+	for end < len(s.content) && isWordChar(s.content[end]) && s.content[end] != '$' {
+		end++
+	}
+
+	// Not a dollar quote, but a positional parameter like $1.
+	if s.peek(end) != '$' || (end > i+1 && !isWordStart(s.content[i+1])) {
+		return i + 1, nil
+	}
+
+	tag := s.content[i : end+1]
+
+	closing := bytes.Index(s.content[end+1:], tag)
+	if closing < 0 {
+		return len(s.content), s.errorf(i, "unterminated %s quote", tag)
+	}
+
+	return end + 1 + closing + len(tag), nil
+}
+
+// backslashEscapes reports whether the string literal starting at i treats
+// backslashes as escapes. That is the case for every MySQL string and for the
+// PostgreSQL E'...' strings.
+func (s *splitter) backslashEscapes(i int) bool {
+	switch s.dialect {
+	case MySQL:
+		return true
+	case PostgreSQL:
+		prefix := s.peek(i - 1)
+		return (prefix == 'E' || prefix == 'e') && !isWordChar(s.peek(i-2))
+	}
+
+	return false
+}
+
+func (s *splitter) peek(i int) byte {
+	if i < 0 || i >= len(s.content) {
+		return 0
+	}
+
+	return s.content[i]
+}
+
+func (s *splitter) errorf(offset int, format string, args ...interface{}) error {
+	line := bytes.Count(s.content[:offset], []byte("\n")) + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func atLineStart(content []byte, i int) bool {
+	for i--; i >= 0; i-- {
+		switch content[i] {
+		case '\n':
+			return true
+		case ' ', '\t', '\r':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func lineEnd(content []byte, i int) int {
+	if end := bytes.IndexByte(content[i:], '\n'); end >= 0 {
+		return i + end
+	}
+
+	return len(content)
+}
+
+func hasWordPrefix(content []byte, word string) bool {
+	return len(content) > len(word) &&
+		strings.EqualFold(string(content[:len(word)]), word) &&
+		isSpace(content[len(word)])
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isWordChar(c byte) bool {
+	return isWordStart(c) || (c >= '0' && c <= '9') || c == '$'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
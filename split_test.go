@@ -0,0 +1,150 @@
+package gloat
+
+import (
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func statementsSQL(statements []Statement) (sqls []string) {
+	for _, statement := range statements {
+		sqls = append(sqls, statement.SQL)
+	}
+
+	return
+}
+
+func TestSplitStatements(t *testing.T) {
+	content := []byte(`
+-- Users; the people.
+CREATE TABLE users (
+    id    bigserial PRIMARY KEY NOT NULL,
+    name  character varying DEFAULT 'a;b' NOT NULL
+);
+
+/* Tokens; for resets. */
+ALTER TABLE users ADD COLUMN "token;" character varying;
+-- Trailing comment.
+`)
+
+	statements, err := SplitStatements(content, SQLite3)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		"-- Users; the people.\nCREATE TABLE users (\n    id    bigserial PRIMARY KEY NOT NULL,\n    name  character varying DEFAULT 'a;b' NOT NULL\n)",
+		"/* Tokens; for resets. */\nALTER TABLE users ADD COLUMN \"token;\" character varying",
+	}, statementsSQL(statements))
+
+	assert.Equal(t, 1, statements[0].Offset)
+	assert.Equal(t, "/* Tokens;", string(content[statements[1].Offset:statements[1].Offset+10]))
+}
+
+func TestSplitStatements_PostgreSQLDollarQuoting(t *testing.T) {
+	content := []byte(`
+CREATE FUNCTION touch() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at = now();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION quoted() RETURNS text AS $body$ SELECT '$$;' $body$ LANGUAGE sql;
+PREPARE get_user AS SELECT * FROM users WHERE id = $1;
+SELECT E'it\'s;', 'C:\';
+`)
+
+	statements, err := SplitStatements(content, PostgreSQL)
+	assert.Nil(t, err)
+
+	assert.Len(t, 4, statements)
+	assert.Equal(t, "CREATE FUNCTION quoted() RETURNS text AS $body$ SELECT '$$;' $body$ LANGUAGE sql", statements[1].SQL)
+	assert.Equal(t, "PREPARE get_user AS SELECT * FROM users WHERE id = $1", statements[2].SQL)
+	assert.Equal(t, `SELECT E'it\'s;', 'C:\'`, statements[3].SQL)
+}
+
+func TestSplitStatements_PostgreSQLBeginAtomic(t *testing.T) {
+	content := []byte(`
+CREATE FUNCTION add(a integer, b integer) RETURNS integer
+LANGUAGE SQL
+BEGIN ATOMIC
+    SELECT CASE WHEN a IS NULL THEN 0 ELSE a END + b;
+END;
+SELECT add(1, 2);
+`)
+
+	statements, err := SplitStatements(content, PostgreSQL)
+	assert.Nil(t, err)
+
+	assert.Len(t, 2, statements)
+	assert.Equal(t, "SELECT add(1, 2)", statements[1].SQL)
+}
+
+func TestSplitStatements_MySQLDelimiter(t *testing.T) {
+	content := []byte(`
+DELIMITER //
+CREATE PROCEDURE count_users()
+BEGIN
+    SELECT COUNT(*) FROM users; # Semicolons; everywhere.
+END//
+DELIMITER ;
+
+SELECT 'it\'s;', ` + "`weird;name`" + ` FROM users;
+`)
+
+	statements, err := SplitStatements(content, MySQL)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		"CREATE PROCEDURE count_users()\nBEGIN\n    SELECT COUNT(*) FROM users; # Semicolons; everywhere.\nEND",
+		"SELECT 'it\\'s;', `weird;name` FROM users",
+	}, statementsSQL(statements))
+}
+
+func TestSplitStatements_SQLite3Trigger(t *testing.T) {
+	content := []byte(`
+CREATE TRIGGER touch_users AFTER UPDATE ON users
+BEGIN
+    UPDATE users SET kind = CASE WHEN NEW.admin THEN 'admin' ELSE 'user' END WHERE id = NEW.id;
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+DROP TABLE posts;
+`)
+
+	statements, err := SplitStatements(content, SQLite3)
+	assert.Nil(t, err)
+
+	assert.Len(t, 2, statements)
+	assert.Equal(t, "DROP TABLE posts", statements[1].SQL)
+}
+
+func TestSplitStatements_Unterminated(t *testing.T) {
+	for _, content := range []string{
+		"SELECT 'unterminated;",
+		"SELECT 1; /* unterminated",
+		"SELECT $$ unterminated",
+	} {
+		_, err := SplitStatements([]byte(content), PostgreSQL)
+		assert.Error(t, err)
+	}
+}
+
+func TestSQLExecutor_Up_Split(t *testing.T) {
+	migration := &Migration{
+		UpSQL:   []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);\nINSERT INTO users (id) VALUES (1);\n"),
+		Version: 20180101000000,
+		Options: DefaultMigrationOptions(),
+	}
+
+	exe := NewSQLExecutor(db, WithDialect(Dialect(dbDriver)))
+
+	cleanState(func() {
+		err := exe.Up(migration, new(testingStore))
+		assert.Nil(t, err)
+
+		var count int
+		err = db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count)
+		assert.Nil(t, err)
+
+		assert.Equal(t, 1, count)
+	})
+}
@@ -0,0 +1,79 @@
+package gloat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Squash combines the UpSQL of every migration up to and including a version
+// into a single migration with the same version, so fresh databases apply
+// one migration instead of all of them. The squashed migration is
+// irreversible. It runs in a transaction only if all of the combined
+// migrations do. Migrations written in Go cannot be squashed, neither can
+// templated migrations be squashed with plain ones.
+//
+// The squashed migration and the combined ones, oldest first, are returned.
+func (m Migrations) Squash(version int64) (*Migration, Migrations, error) {
+	if !m.Has(version) {
+		return nil, nil, fmt.Errorf("cannot squash to unknown version %d", version)
+	}
+
+	var originals Migrations
+	for _, migration := range m {
+		if migration.Version <= version {
+			originals = append(originals, migration)
+		}
+	}
+	sort.Sort(originals)
+
+	squashed := &Migration{
+		Path:    fmt.Sprintf("%d_squashed", version),
+		Version: version,
+		Options: DefaultMigrationOptions(),
+	}
+
+	squashed.Options.Template = originals[0].Options.Template
+
+	var upSQL bytes.Buffer
+	for _, migration := range originals {
+		if migration.UpFunc != nil {
+			return nil, nil, fmt.Errorf("migration %d is written in Go and cannot be squashed", migration.Version)
+		}
+
+		if migration.Options.Template != squashed.Options.Template {
+			return nil, nil, fmt.Errorf("migration %d cannot be squashed with migration %d, only one of them is templated", migration.Version, originals[0].Version)
+		}
+
+		squashed.Options.Transaction = squashed.Options.Transaction && migration.Options.Transaction
+		squashed.Options.Split = squashed.Options.Split && migration.Options.Split
+
+		fmt.Fprintf(&upSQL, "-- Squashed %d %s\n%s\n\n", migration.Version, migration.Name(), terminateStatement(migration.UpSQL))
+	}
+
+	squashed.UpSQL = bytes.TrimRight(upSQL.Bytes(), "\n")
+	squashed.UpSQL = append(squashed.UpSQL, '\n')
+
+	return squashed, originals, nil
+}
+
+// WriteSquashBookkeeping writes the statements that make a store, which
+// recorded the squashed migrations as applied, record the squashed migration
+// instead. Run them only against databases that applied every squashed
+// migration. Databases that applied none of them apply the squashed migration
+// as usual.
+func WriteSquashBookkeeping(w io.Writer, squashed *Migration, originals Migrations, store StatementStore) error {
+	var script bytes.Buffer
+
+	fmt.Fprintf(&script, "-- Generated by gloat %s, recording the squashed migration %d.\n", Version, squashed.Version)
+	fmt.Fprintf(&script, "BEGIN;\n")
+	for _, migration := range originals {
+		fmt.Fprintf(&script, "%s\n", store.RemoveStatement(migration))
+	}
+	fmt.Fprintf(&script, "%s\n", store.InsertStatement(squashed))
+	fmt.Fprintf(&script, "COMMIT;\n")
+
+	_, err := script.WriteTo(w)
+	return err
+}
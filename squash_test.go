@@ -0,0 +1,103 @@
+package gloat
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func TestMigrationsSquash(t *testing.T) {
+	migrations := Migrations{
+		&Migration{
+			UpSQL:   []byte("CREATE INDEX CONCURRENTLY users_email_idx ON users (email)\n"),
+			Path:    "20180905150724_add_users_email_index",
+			Version: 20180905150724,
+			Options: MigrationOptions{Transaction: false, Split: true},
+		},
+		&Migration{
+			UpSQL:   []byte("CREATE TABLE users (email TEXT);\n"),
+			DownSQL: []byte("DROP TABLE users;\n"),
+			Path:    "20170329154959_introduce_domain_model",
+			Version: 20170329154959,
+			Options: DefaultMigrationOptions(),
+		},
+		&Migration{
+			UpSQL:   []byte("DROP TABLE users;\n"),
+			Path:    "20180920181906_drop_users",
+			Version: 20180920181906,
+			Options: DefaultMigrationOptions(),
+		},
+	}
+
+	squashed, originals, err := migrations.Squash(20180905150724)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 20180905150724, squashed.Version)
+	assert.Equal(t, "squashed", squashed.Name())
+	assert.False(t, squashed.Reversible())
+	assert.False(t, squashed.Options.Transaction)
+	assert.Equal(t, "-- Squashed 20170329154959 introduce_domain_model\nCREATE TABLE users (email TEXT);\n\n-- Squashed 20180905150724 add_users_email_index\nCREATE INDEX CONCURRENTLY users_email_idx ON users (email);\n", string(squashed.UpSQL))
+
+	assert.Len(t, 2, originals)
+	assert.Equal(t, 20170329154959, originals[0].Version)
+
+	_, _, err = migrations.Squash(42)
+	assert.Error(t, err)
+}
+
+func TestMigrationsSquash_TrailingComments(t *testing.T) {
+	migrations := Migrations{
+		&Migration{
+			UpSQL:   []byte("CREATE TABLE a (id int)\n-- trailing note\n"),
+			Path:    "20170329154959_create_a",
+			Version: 20170329154959,
+			Options: DefaultMigrationOptions(),
+		},
+		&Migration{
+			UpSQL:   []byte("CREATE TABLE b (id int) -- note"),
+			Path:    "20180905150724_create_b",
+			Version: 20180905150724,
+			Options: DefaultMigrationOptions(),
+		},
+	}
+
+	squashed, _, err := migrations.Squash(20180905150724)
+	assert.Nil(t, err)
+
+	statements, err := SplitStatements(squashed.UpSQL, SQLite3)
+	assert.Nil(t, err)
+	assert.Len(t, 2, statements)
+}
+
+func TestMigrationsSquash_GoMigration(t *testing.T) {
+	migrations := Migrations{
+		&Migration{Version: 20170329154959, UpFunc: func(context.Context, SQLExecer) error { return nil }},
+	}
+
+	_, _, err := migrations.Squash(20170329154959)
+	assert.Error(t, err)
+}
+
+func TestWriteSquashBookkeeping(t *testing.T) {
+	squashed := &Migration{UpSQL: []byte("SELECT 1;\n"), Path: "20180905150724_squashed", Version: 20180905150724}
+	originals := Migrations{
+		&Migration{Path: "20170329154959_introduce_domain_model", Version: 20170329154959},
+		&Migration{Path: "20180905150724_add_users_token", Version: 20180905150724},
+	}
+
+	store := newDatabaseStore(nil, SQLite3, nil)
+
+	var out bytes.Buffer
+
+	err := WriteSquashBookkeeping(&out, squashed, originals, store)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "-- Generated by gloat "+Version+", recording the squashed migration 20180905150724.\n"+
+		"BEGIN;\n"+
+		store.RemoveStatement(originals[0])+"\n"+
+		store.RemoveStatement(originals[1])+"\n"+
+		store.InsertStatement(squashed)+"\n"+
+		"COMMIT;\n", out.String())
+}
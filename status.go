@@ -0,0 +1,121 @@
+package gloat
+
+import (
+	"context"
+	"time"
+)
+
+// MigrationState describes whether a migration has been applied.
+type MigrationState string
+
+// The states a migration can be in.
+const (
+	// StateApplied is a migration available from the source and recorded in
+	// the store.
+	StateApplied MigrationState = "applied"
+
+	// StatePending is a migration available from the source, that is not yet
+	// recorded in the store.
+	StatePending MigrationState = "pending"
+
+	// StateMissing is a migration recorded in the store, that is no longer
+	// available from the source.
+	StateMissing MigrationState = "missing"
+
+	// StateDirty is a migration that failed halfway outside of a
+	// transaction. See DirtyStore.
+	StateDirty MigrationState = "dirty"
+)
+
+// MigrationStatus is the state of a single migration.
+type MigrationStatus struct {
+	Version int64
+	Name    string
+	State   MigrationState
+
+	// AppliedAt is the time the migration was applied at. It is zero for
+	// pending migrations and for stores that do not record it.
+	AppliedAt time.Time
+}
+
+// Status lists every migration known to either the source or the store,
+// ordered by version.
+type Status struct {
+	Migrations []MigrationStatus
+}
+
+// Pending returns the migrations that are not applied yet.
+func (s *Status) Pending() []MigrationStatus {
+	return s.filter(StatePending)
+}
+
+// Missing returns the applied migrations that are no longer available from the
+// source.
+func (s *Status) Missing() []MigrationStatus {
+	return s.filter(StateMissing)
+}
+
+// Dirty returns the migrations that failed halfway and have to be repaired.
+func (s *Status) Dirty() []MigrationStatus {
+	return s.filter(StateDirty)
+}
+
+// Clean is true if there are no pending, missing or dirty migrations.
+func (s *Status) Clean() bool {
+	return len(s.Pending()) == 0 && len(s.Missing()) == 0 && len(s.Dirty()) == 0
+}
+
+func (s *Status) filter(state MigrationState) (statuses []MigrationStatus) {
+	for _, status := range s.Migrations {
+		if status.State == state {
+			statuses = append(statuses, status)
+		}
+	}
+
+	return
+}
+
+// Status builds the status of every migration from the Store and the Source.
+func (c *Gloat) Status() (*Status, error) {
+	return c.StatusContext(context.Background())
+}
+
+// StatusContext builds the status of every migration from the Store and the
+// Source.
+func (c *Gloat) StatusContext(ctx context.Context) (*Status, error) {
+	appliedMigrations, availableMigrations, err := c.collectBoth(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations Migrations
+	migrations = append(migrations, availableMigrations...)
+	migrations = append(migrations, availableMigrations.Except(appliedMigrations)...)
+	migrations.Sort()
+
+	status := &Status{}
+	for _, migration := range migrations {
+		entry := MigrationStatus{
+			Version: migration.Version,
+			Name:    migration.Name(),
+			State:   StatePending,
+		}
+
+		if applied := appliedMigrations.Find(migration.Version); applied != nil {
+			entry.State = StateApplied
+			entry.AppliedAt = applied.AppliedAt
+
+			if !availableMigrations.Has(migration.Version) {
+				entry.State = StateMissing
+			}
+
+			if applied.Dirty {
+				entry.State = StateDirty
+			}
+		}
+
+		status.Migrations = append(status.Migrations, entry)
+	}
+
+	return status, nil
+}
@@ -0,0 +1,62 @@
+package gloat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func TestStatus(t *testing.T) {
+	appliedAt := time.Date(2018, 9, 5, 15, 7, 24, 0, time.UTC)
+
+	gl.Store = &testingStore{
+		applied: Migrations{
+			&Migration{Version: 20170329154959, AppliedAt: appliedAt},
+			&Migration{Version: 20170511172647},
+			&Migration{Version: 20190101000000, Path: "20190101000000_gone"},
+		},
+	}
+
+	status, err := gl.Status()
+	assert.Nil(t, err)
+
+	assert.Len(t, 5, status.Migrations)
+
+	assert.Equal(t, MigrationStatus{
+		Version:   20170329154959,
+		Name:      "introduce_domain_model",
+		State:     StateApplied,
+		AppliedAt: appliedAt,
+	}, status.Migrations[0])
+
+	assert.Equal(t, StateApplied, status.Migrations[1].State)
+	assert.Equal(t, StatePending, status.Migrations[2].State)
+	assert.Equal(t, StatePending, status.Migrations[3].State)
+
+	assert.Equal(t, MigrationStatus{
+		Version: 20190101000000,
+		Name:    "gone",
+		State:   StateMissing,
+	}, status.Migrations[4])
+
+	assert.Len(t, 2, status.Pending())
+	assert.Len(t, 1, status.Missing())
+	assert.False(t, status.Clean())
+}
+
+func TestStatus_Clean(t *testing.T) {
+	gl.Store = &testingStore{
+		applied: Migrations{
+			&Migration{Version: 20170329154959},
+			&Migration{Version: 20170511172647},
+			&Migration{Version: 20180905150724},
+			&Migration{Version: 20180920181906},
+		},
+	}
+
+	status, err := gl.Status()
+	assert.Nil(t, err)
+
+	assert.True(t, status.Clean())
+}
@@ -1,5 +1,13 @@
 package gloat
 
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
 // Store is an interface representing a place where the applied migrations are
 // recorded.
 type Store interface {
@@ -9,132 +17,580 @@ type Store interface {
 	Remove(*Migration, SQLExecer) error
 }
 
+// ContextStore is a Store that can be cancelled through a context. The
+// builtin DatabaseStore implements it.
+type ContextStore interface {
+	Store
+	ContextSource
+
+	InsertContext(context.Context, *Migration, SQLExecer) error
+	RemoveContext(context.Context, *Migration, SQLExecer) error
+}
+
+// SetupStore is a Store that has to prepare itself, like creating its table,
+// before migrations are recorded in it. The builtin DatabaseStore implements
+// it. Executors call Setup before starting the transaction of a migration, as
+// some databases cannot create tables while another connection is in a
+// transaction, or commit the transaction implicitly when they do.
+type SetupStore interface {
+	Store
+
+	Setup(context.Context) error
+}
+
+// DirtyStore is a Store that can record migrations as dirty. The builtin
+// DatabaseStore implements it. Migrations running outside of a transaction are
+// recorded as dirty while they run, so a migration failing halfway is not
+// mistaken for one that never ran.
+type DirtyStore interface {
+	Store
+
+	// SetDirty marks a recorded migration as dirty or clean. Marking a
+	// migration that is not recorded as dirty records it.
+	SetDirty(ctx context.Context, migration *Migration, dirty bool, execer SQLExecer) error
+}
+
+// StatementStore is a Store that can render the statements recording
+// migrations, instead of executing them. The builtin DatabaseStore implements
+// it. It is used to show the bookkeeping of dry runs and SQL scripts.
+type StatementStore interface {
+	Store
+
+	CreateStatements() []string
+	InsertStatement(*Migration) string
+	RemoveStatement(*Migration) string
+}
+
 // DatabaseStore is a Store that keeps the applied migrations in a database
-// table called schema_migrations. The table is automatically created if it
-// does not exist.
+// table called schema_migrations, unless configured otherwise. The table is
+// created by Setup, which the executors call before the transaction of a
+// migration, or otherwise on the first insert or removal. Tables created by
+// older gloat versions, that keep only the migration version, are upgraded in
+// place with the columns for the migration name, the time it was applied at,
+// its duration, checksum, the gloat version that applied it and whether it is
+// dirty.
+//
+// Collecting the applied migrations never writes to the database. A missing
+// table means no applied migrations.
 type DatabaseStore struct {
-	db SQLTransactor
+	db      SQLTransactor
+	dialect Dialect
+	table   string
+	schema  string
 
+	createSchemaStatement        string
 	createTableStatement         string
+	selectColumnsStatement       string
+	addColumnStatements          []storeColumn
 	insertMigrationStatement     string
 	removeMigrationStatement     string
+	selectMigrationStatement     string
+	setDirtyStatement            string
 	selectAllMigrationsStatement string
+
+	upgraded bool
 }
 
-// Insert records a migration version into the schema_migrations table.
+type storeColumn struct {
+	name               string
+	addColumnStatement string
+}
+
+// Insert records a migration version into the migrations table.
 func (s *DatabaseStore) Insert(migration *Migration, execer SQLExecer) error {
+	return s.InsertContext(context.Background(), migration, execer)
+}
+
+// InsertContext records a migration version into the migrations table.
+func (s *DatabaseStore) InsertContext(ctx context.Context, migration *Migration, execer SQLExecer) error {
 	if execer == nil {
 		execer = s.db
 	}
 
-	if err := s.ensureSchemaTableExists(); err != nil {
+	if err := s.lazySetup(ctx); err != nil {
 		return err
 	}
 
-	_, err := execer.Exec(s.insertMigrationStatement, migration.Version)
+	return s.insert(ctx, migration, false, execer)
+}
+
+func (s *DatabaseStore) insert(ctx context.Context, migration *Migration, dirty bool, execer SQLExecer) error {
+	_, err := execContext(
+		ctx,
+		execer,
+		s.insertMigrationStatement,
+		migration.Version,
+		migration.Name(),
+		time.Now().UTC(),
+		int64(migration.Duration/time.Millisecond),
+		migrationChecksum(migration),
+		Version,
+		dirty,
+	)
 	return err
 }
 
-// Remove removes a migration version from the schema_migrations table.
+// CreateStatements renders the statements creating the migrations table and
+// its schema, if they do not exist, and adding the columns missing from the
+// tables of older gloat versions. Only PostgreSQL can skip the columns that
+// exist, so the other dialects get them commented out, to be run by hand
+// against such tables.
+func (s *DatabaseStore) CreateStatements() []string {
+	var statements []string
+	if s.createSchemaStatement != "" {
+		statements = append(statements, dedent(s.createSchemaStatement))
+	}
+
+	statements = append(statements, dedent(s.createTableStatement))
+
+	for i, column := range s.addColumnStatements {
+		switch {
+		case s.dialect == PostgreSQL:
+			statements = append(statements, strings.Replace(column.addColumnStatement, "ADD COLUMN", "ADD COLUMN IF NOT EXISTS", 1))
+		case i == 0:
+			statements = append(statements, "-- Tables created by older gloat versions miss some of these columns.\n-- "+column.addColumnStatement)
+		default:
+			statements = append(statements, "-- "+column.addColumnStatement)
+		}
+	}
+
+	return statements
+}
+
+// InsertStatement renders the statement recording a migration, with the
+// values inlined. The statement records the time it is executed at. The
+// duration is recorded only if the migration has one.
+func (s *DatabaseStore) InsertStatement(migration *Migration) string {
+	duration := "NULL"
+	if migration.Duration != 0 {
+		duration = fmt.Sprint(int64(migration.Duration / time.Millisecond))
+	}
+
+	return fmt.Sprintf(
+		"INSERT INTO %s (version, name, applied_at, duration_ms, checksum, gloat_version, dirty) VALUES (%d, %s, %s, %s, %s, %s, FALSE);",
+		s.qualifiedTable(),
+		migration.Version,
+		s.dialect.quoteString(migration.Name()),
+		s.dialect.currentTimestamp(),
+		duration,
+		s.dialect.quoteString(migrationChecksum(migration)),
+		s.dialect.quoteString(Version),
+	)
+}
+
+// Remove removes a migration version from the migrations table.
 func (s *DatabaseStore) Remove(migration *Migration, execer SQLExecer) error {
+	return s.RemoveContext(context.Background(), migration, execer)
+}
+
+// RemoveContext removes a migration version from the migrations table.
+func (s *DatabaseStore) RemoveContext(ctx context.Context, migration *Migration, execer SQLExecer) error {
 	if execer == nil {
 		execer = s.db
 	}
 
-	if err := s.ensureSchemaTableExists(); err != nil {
+	if err := s.lazySetup(ctx); err != nil {
 		return err
 	}
 
-	_, err := execer.Exec(s.removeMigrationStatement, migration.Version)
+	_, err := execContext(ctx, execer, s.removeMigrationStatement, migration.Version)
 	return err
 }
 
-// Collect builds a slice of migrations with the versions of the recorded
-// applied migrations.
-func (s *DatabaseStore) Collect() (migrations Migrations, err error) {
-	if err = s.ensureSchemaTableExists(); err != nil {
+// SetDirty marks a recorded migration as dirty or clean. Marking a migration
+// that is not recorded as dirty records it.
+func (s *DatabaseStore) SetDirty(ctx context.Context, migration *Migration, dirty bool, execer SQLExecer) error {
+	if execer == nil {
+		execer = s.db
+	}
+
+	if err := s.lazySetup(ctx); err != nil {
+		return err
+	}
+
+	rows, err := queryContext(ctx, execer, s.selectMigrationStatement, migration.Version)
+	if err != nil {
+		return err
+	}
+
+	recorded := rows.Next()
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if !recorded {
+		if !dirty {
+			return nil
+		}
+
+		return s.insert(ctx, migration, true, execer)
+	}
+
+	_, err = execContext(ctx, execer, s.setDirtyStatement, dirty, migration.Version)
+	return err
+}
+
+// RemoveStatement renders the statement removing a migration, with the
+// values inlined.
+func (s *DatabaseStore) RemoveStatement(migration *Migration) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE version=%d;", s.qualifiedTable(), migration.Version)
+}
+
+// Setup creates the migrations table, or upgrades the tables of older gloat
+// versions.
+func (s *DatabaseStore) Setup(ctx context.Context) error {
+	return s.ensureSchemaTableExists(ctx)
+}
+
+// Collect builds a slice of migrations with the versions and the metadata of
+// the recorded applied migrations.
+func (s *DatabaseStore) Collect() (Migrations, error) {
+	return s.CollectContext(context.Background())
+}
+
+// CollectContext builds a slice of migrations with the versions and the
+// metadata of the recorded applied migrations.
+func (s *DatabaseStore) CollectContext(ctx context.Context) (migrations Migrations, err error) {
+	exists, err := s.tableExists(ctx)
+	if err != nil || !exists {
 		return
 	}
 
-	rows, err := s.db.Query(s.selectAllMigrationsStatement)
+	columns, err := s.columns(ctx)
+	if err != nil {
+		return
+	}
+
+	// Tables of older gloat versions are upgraded only when written to, so
+	// select NULL for the columns they miss.
+	selectColumns := []string{"version"}
+	for _, column := range s.addColumnStatements {
+		if columns[column.name] {
+			selectColumns = append(selectColumns, column.name)
+		} else {
+			selectColumns = append(selectColumns, "NULL")
+		}
+	}
+
+	rows, err := queryContext(ctx, s.db, fmt.Sprintf(s.selectAllMigrationsStatement, strings.Join(selectColumns, ", ")))
 	if err != nil {
 		return
 	}
 	defer rows.Close()
 
 	for rows.Next() {
+		var (
+			name         sql.NullString
+			appliedAt    nullTime
+			duration     sql.NullInt64
+			sum          sql.NullString
+			gloatVersion sql.NullString
+			dirty        sql.NullBool
+		)
+
 		migration := &Migration{}
-		if err = rows.Scan(&migration.Version); err != nil {
+		if err = rows.Scan(&migration.Version, &name, &appliedAt, &duration, &sum, &gloatVersion, &dirty); err != nil {
 			return
 		}
 
+		if name.String != "" {
+			migration.Path = fmt.Sprintf("%d_%s", migration.Version, name.String)
+		}
+		migration.AppliedAt = appliedAt.Time
+		migration.Duration = time.Duration(duration.Int64) * time.Millisecond
+		migration.Checksum = sum.String
+		migration.GloatVersion = gloatVersion.String
+		migration.Dirty = dirty.Bool
+
 		migrations = append(migrations, migration)
 	}
 
+	err = rows.Err()
+
 	return
 }
 
-func (s *DatabaseStore) ensureSchemaTableExists() error {
-	_, err := s.db.Exec(s.createTableStatement)
-	return err
+// qualifiedTable returns the quoted, and if needed schema qualified, name of the table
+// the applied migrations are recorded in.
+func (s *DatabaseStore) qualifiedTable() string {
+	table := s.dialect.QuoteIdentifier(s.table)
+	if s.schema != "" {
+		table = s.dialect.QuoteIdentifier(s.schema) + "." + table
+	}
+
+	return table
 }
 
-// NewPostgreSQLStore creates a Store for PostgreSQL.
-func NewPostgreSQLStore(db SQLTransactor) Store {
-	return &DatabaseStore{
-		db: db,
-		createTableStatement: `
-			CREATE TABLE IF NOT EXISTS schema_migrations (
-				version BIGINT PRIMARY KEY NOT NULL
-			)`,
-		insertMigrationStatement: `
-			INSERT INTO schema_migrations (version)
-			VALUES ($1)`,
-		removeMigrationStatement: `
-			DELETE FROM schema_migrations
-			WHERE version=$1`,
-		selectAllMigrationsStatement: `
-			SELECT version
-			FROM schema_migrations`,
+func (s *DatabaseStore) ensureSchemaTableExists(ctx context.Context) error {
+	if s.createSchemaStatement != "" {
+		if _, err := execContext(ctx, s.db, s.createSchemaStatement); err != nil {
+			return err
+		}
+	}
+
+	if _, err := execContext(ctx, s.db, s.createTableStatement); err != nil {
+		return err
+	}
+
+	if s.upgraded {
+		return nil
+	}
+
+	if err := s.upgradeSchemaTable(ctx); err != nil {
+		return err
 	}
+
+	s.upgraded = true
+
+	return nil
 }
 
-// NewMySQLStore creates a Store for MySQL.
-func NewMySQLStore(db SQLTransactor) Store {
-	return &DatabaseStore{
-		db: db,
-		createTableStatement: `
-			CREATE TABLE IF NOT EXISTS schema_migrations (
-				version BIGINT PRIMARY KEY NOT NULL
-			)`,
-		insertMigrationStatement: `
-			INSERT INTO schema_migrations (version)
-			VALUES (?)`,
-		removeMigrationStatement: `
-			DELETE FROM schema_migrations
-			WHERE version=?`,
-		selectAllMigrationsStatement: `
-			SELECT version
-			FROM schema_migrations`,
+// lazySetup creates or upgrades the migrations table for the callers that did
+// not call Setup. It always goes through the database, never through the
+// execer of the caller, and is skipped once the table is set up, so it does
+// not run next to the transaction of a migration that went through Setup.
+func (s *DatabaseStore) lazySetup(ctx context.Context) error {
+	if s.upgraded {
+		return nil
+	}
+
+	return s.ensureSchemaTableExists(ctx)
+}
+
+// upgradeSchemaTable adds the columns missing from a migrations table
+// created by an older gloat version.
+func (s *DatabaseStore) upgradeSchemaTable(ctx context.Context) error {
+	existingColumns, err := s.columns(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, column := range s.addColumnStatements {
+		if existingColumns[column.name] {
+			continue
+		}
+
+		if _, err := execContext(ctx, s.db, column.addColumnStatement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *DatabaseStore) columns(ctx context.Context) (map[string]bool, error) {
+	rows, err := queryContext(ctx, s.db, s.selectColumnsStatement)
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	existingColumns := make(map[string]bool)
+	for _, column := range columns {
+		existingColumns[strings.ToLower(column)] = true
+	}
+
+	return existingColumns, nil
+}
+
+func (s *DatabaseStore) tableExists(ctx context.Context) (bool, error) {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+
+	switch s.dialect {
+	case PostgreSQL:
+		rows, err = queryContext(ctx, s.db, `
+			SELECT 1
+			FROM information_schema.tables
+			WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2`, s.schema, s.table)
+	case MySQL:
+		rows, err = queryContext(ctx, s.db, `
+			SELECT 1
+			FROM information_schema.tables
+			WHERE table_schema = DATABASE() AND table_name = ?`, s.table)
+	default:
+		rows, err = queryContext(ctx, s.db, `
+			SELECT 1
+			FROM sqlite_master
+			WHERE type = 'table' AND name = ?`, s.table)
+	}
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	exists := rows.Next()
+
+	return exists, rows.Err()
+}
+
+func migrationChecksum(migration *Migration) string {
+	if migration.Checksum != "" {
+		return migration.Checksum
+	}
+
+	return checksum(migration.UpSQL)
+}
+
+// nullTime scans timestamps from drivers that do not parse them on their own,
+// like MySQL without parseTime=true.
+type nullTime struct {
+	Time time.Time
+}
+
+var nullTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+// Scan implements the sql.Scanner interface.
+func (t *nullTime) Scan(value interface{}) (err error) {
+	switch value := value.(type) {
+	case nil:
+		t.Time = time.Time{}
+	case time.Time:
+		t.Time = value
+	case []byte:
+		return t.Scan(string(value))
+	case string:
+		for _, layout := range nullTimeLayouts {
+			if t.Time, err = time.Parse(layout, value); err == nil {
+				return nil
+			}
+		}
+	default:
+		err = fmt.Errorf("cannot scan %T into a time", value)
+	}
+
+	return
+}
+
+func setup(ctx context.Context, store Store) error {
+	if store, ok := store.(SetupStore); ok {
+		return store.Setup(ctx)
+	}
+
+	return nil
+}
+
+func insert(ctx context.Context, store Store, migration *Migration, execer SQLExecer) error {
+	if store, ok := store.(ContextStore); ok {
+		return store.InsertContext(ctx, migration, execer)
 	}
+
+	return store.Insert(migration, execer)
+}
+
+func remove(ctx context.Context, store Store, migration *Migration, execer SQLExecer) error {
+	if store, ok := store.(ContextStore); ok {
+		return store.RemoveContext(ctx, migration, execer)
+	}
+
+	return store.Remove(migration, execer)
+}
+
+// StoreOption configures a DatabaseStore.
+type StoreOption func(*DatabaseStore)
+
+// WithTable sets the name of the table the applied migrations are recorded
+// in. The default is schema_migrations.
+func WithTable(table string) StoreOption {
+	return func(s *DatabaseStore) { s.table = table }
+}
+
+// WithSchema sets the schema of the table the applied migrations are recorded
+// in. The schema is automatically created if it does not exist. Only the
+// PostgreSQL store supports schemas, the other stores ignore it.
+func WithSchema(schema string) StoreOption {
+	return func(s *DatabaseStore) { s.schema = schema }
+}
+
+// NewPostgreSQLStore creates a Store for PostgreSQL.
+func NewPostgreSQLStore(db SQLTransactor, options ...StoreOption) Store {
+	return newDatabaseStore(db, PostgreSQL, options)
+}
+
+// NewMySQLStore creates a Store for MySQL.
+func NewMySQLStore(db SQLTransactor, options ...StoreOption) Store {
+	return newDatabaseStore(db, MySQL, options)
 }
 
 // NewSQLite3Store creates a Store for SQLite3.
-func NewSQLite3Store(db SQLTransactor) Store {
-	return &DatabaseStore{
-		db: db,
-		createTableStatement: `
-			CREATE TABLE IF NOT EXISTS schema_migrations (
-				version BIGINT PRIMARY KEY NOT NULL
-			)`,
-		insertMigrationStatement: `
-			INSERT INTO schema_migrations (version)
-			VALUES (?)`,
-		removeMigrationStatement: `
-			DELETE FROM schema_migrations
-			WHERE version=?`,
-		selectAllMigrationsStatement: `
-			SELECT version
-			FROM schema_migrations`,
+func NewSQLite3Store(db SQLTransactor, options ...StoreOption) Store {
+	return newDatabaseStore(db, SQLite3, options)
+}
+
+func newDatabaseStore(db SQLTransactor, dialect Dialect, options []StoreOption) *DatabaseStore {
+	s := &DatabaseStore{
+		db:      db,
+		dialect: dialect,
+		table:   "schema_migrations",
 	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	if dialect != PostgreSQL {
+		s.schema = ""
+	}
+
+	table := s.qualifiedTable()
+
+	if s.schema != "" {
+		s.createSchemaStatement = fmt.Sprintf(`
+			CREATE SCHEMA IF NOT EXISTS %s`, dialect.QuoteIdentifier(s.schema))
+	}
+
+	s.createTableStatement = fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %s (
+				version BIGINT PRIMARY KEY NOT NULL,
+				name VARCHAR(255),
+				applied_at %s,
+				duration_ms BIGINT,
+				checksum VARCHAR(64),
+				gloat_version VARCHAR(32),
+				dirty BOOLEAN
+			)`, table, dialect.timestampType())
+	s.selectColumnsStatement = fmt.Sprintf(`
+			SELECT *
+			FROM %s
+			WHERE 1=0`, table)
+	s.addColumnStatements = []storeColumn{
+		{"name", fmt.Sprintf(`ALTER TABLE %s ADD COLUMN name VARCHAR(255)`, table)},
+		{"applied_at", fmt.Sprintf(`ALTER TABLE %s ADD COLUMN applied_at %s`, table, dialect.timestampType())},
+		{"duration_ms", fmt.Sprintf(`ALTER TABLE %s ADD COLUMN duration_ms BIGINT`, table)},
+		{"checksum", fmt.Sprintf(`ALTER TABLE %s ADD COLUMN checksum VARCHAR(64)`, table)},
+		{"gloat_version", fmt.Sprintf(`ALTER TABLE %s ADD COLUMN gloat_version VARCHAR(32)`, table)},
+		{"dirty", fmt.Sprintf(`ALTER TABLE %s ADD COLUMN dirty BOOLEAN`, table)},
+	}
+	s.insertMigrationStatement = fmt.Sprintf(`
+			INSERT INTO %s (version, name, applied_at, duration_ms, checksum, gloat_version, dirty)
+			VALUES (%s)`, table, dialect.placeholders(7))
+	s.removeMigrationStatement = fmt.Sprintf(`
+			DELETE FROM %s
+			WHERE version=%s`, table, dialect.placeholder(1))
+	s.selectMigrationStatement = fmt.Sprintf(`
+			SELECT version
+			FROM %s
+			WHERE version=%s`, table, dialect.placeholder(1))
+	s.setDirtyStatement = fmt.Sprintf(`
+			UPDATE %s
+			SET dirty=%s
+			WHERE version=%s`, table, dialect.placeholder(1), dialect.placeholder(2))
+	s.selectAllMigrationsStatement = fmt.Sprintf(`
+			SELECT %%s
+			FROM %s`, table)
+
+	return s
 }
@@ -1,10 +1,13 @@
 package gloat
 
 import (
+	"context"
 	"database/sql"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gsamokovarov/assert"
 )
@@ -34,6 +37,36 @@ func TestDatabaseStore_Insert(t *testing.T) {
 	})
 }
 
+type legacyTransactor struct{ db *sql.DB }
+
+func (t legacyTransactor) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.db.Exec(query, args...)
+}
+
+func (t legacyTransactor) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.db.Query(query, args...)
+}
+
+func (t legacyTransactor) Begin() (*sql.Tx, error) { return t.db.Begin() }
+
+func TestDatabaseStore_LegacyTransactor(t *testing.T) {
+	td := filepath.Join(dbSrc, "20170329154959_introduce_domain_model")
+
+	migration, err := MigrationFromBytes(td, ioutil.ReadFile)
+	assert.Nil(t, err)
+
+	dbStore := newDatabaseStore(legacyTransactor{db}, Dialect(dbDriver), nil)
+
+	cleanState(func() {
+		err := dbStore.Insert(migration, nil)
+		assert.Nil(t, err)
+
+		migrations, err := dbStore.Collect()
+		assert.Nil(t, err)
+		assert.Len(t, 1, migrations)
+	})
+}
+
 func TestDatabaseStore_Remove(t *testing.T) {
 	td := filepath.Join(dbSrc, "20170329154959_introduce_domain_model")
 
@@ -44,7 +77,10 @@ func TestDatabaseStore_Remove(t *testing.T) {
 	assert.Nil(t, err)
 
 	cleanState(func() {
-		err := dbStore.Insert(migration, nil)
+		err := dbStore.(SetupStore).Setup(context.Background())
+		assert.Nil(t, err)
+
+		err = dbStore.Insert(migration, nil)
 		assert.Nil(t, err)
 
 		err = dbStore.Remove(migration, nil)
@@ -67,16 +103,177 @@ func TestDatabaseStore_Collect(t *testing.T) {
 	assert.Nil(t, err)
 
 	cleanState(func() {
-		err := dbStore.Insert(migration, nil)
+		err := dbStore.(SetupStore).Setup(context.Background())
+		assert.Nil(t, err)
+
+		err = dbStore.Insert(migration, nil)
+		assert.Nil(t, err)
+
+		migrations, err := dbStore.Collect()
+		assert.Nil(t, err)
+
+		assert.Len(t, 1, migrations)
+		assert.Equal(t, 20170329154959, migrations[0].Version)
+		assert.Equal(t, "introduce_domain_model", migrations[0].Name())
+		assert.Equal(t, checksum(migration.UpSQL), migrations[0].Checksum)
+		assert.Equal(t, Version, migrations[0].GloatVersion)
+		assert.False(t, migrations[0].AppliedAt.IsZero())
+	})
+}
+
+func TestDatabaseStore_UpgradesLegacyTable(t *testing.T) {
+	td := filepath.Join(dbSrc, "20170329154959_introduce_domain_model")
+
+	migration, err := MigrationFromBytes(td, ioutil.ReadFile)
+	assert.Nil(t, err)
+
+	dbStore, err := databaseStoreFactory(dbDriver, db)
+	assert.Nil(t, err)
+
+	cleanState(func() {
+		_, err := db.Exec(`CREATE TABLE schema_migrations (version BIGINT PRIMARY KEY NOT NULL)`)
+		assert.Nil(t, err)
+
+		_, err = db.Exec(`INSERT INTO schema_migrations (version) VALUES (20170329154959)`)
 		assert.Nil(t, err)
 
 		migrations, err := dbStore.Collect()
 		assert.Nil(t, err)
 
-		expectedMigrations := Migrations{
-			&Migration{Version: 20170329154959},
-		}
+		assert.Len(t, 1, migrations)
+		assert.Equal(t, 20170329154959, migrations[0].Version)
+		assert.Equal(t, "", migrations[0].Checksum)
+		assert.True(t, migrations[0].AppliedAt.IsZero())
+
+		err = dbStore.(SetupStore).Setup(context.Background())
+		assert.Nil(t, err)
+
+		err = dbStore.Remove(migration, nil)
+		assert.Nil(t, err)
+
+		migration.Duration = 1500 * time.Millisecond
+
+		err = dbStore.Insert(migration, nil)
+		assert.Nil(t, err)
+
+		migrations, err = dbStore.Collect()
+		assert.Nil(t, err)
+
+		assert.Len(t, 1, migrations)
+		assert.Equal(t, 1500*time.Millisecond, migrations[0].Duration)
+		assert.Equal(t, "introduce_domain_model", migrations[0].Name())
+	})
+}
+
+func TestDatabaseStore_WithTable(t *testing.T) {
+	td := filepath.Join(dbSrc, "20170329154959_introduce_domain_model")
+
+	migration, err := MigrationFromBytes(td, ioutil.ReadFile)
+	assert.Nil(t, err)
+
+	dbStore, err := databaseStoreFactory(dbDriver, db, WithTable("gloat_migrations"))
+	assert.Nil(t, err)
+
+	defer db.Exec(`DROP TABLE IF EXISTS gloat_migrations`)
+
+	cleanState(func() {
+		err := dbStore.(SetupStore).Setup(context.Background())
+		assert.Nil(t, err)
+
+		err = dbStore.Insert(migration, nil)
+		assert.Nil(t, err)
+
+		var version int64
+
+		err = db.QueryRow(`SELECT version FROM gloat_migrations`).Scan(&version)
+		assert.Nil(t, err)
+
+		assert.Equal(t, 20170329154959, version)
+
+		_, err = db.Exec(`SELECT version FROM schema_migrations`)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestDatabaseStore_WithSchema(t *testing.T) {
+	if dbDriver != "postgres" {
+		t.Skip("schemas are supported only for PostgreSQL")
+	}
+
+	td := filepath.Join(dbSrc, "20170329154959_introduce_domain_model")
+
+	migration, err := MigrationFromBytes(td, ioutil.ReadFile)
+	assert.Nil(t, err)
+
+	dbStore, err := databaseStoreFactory(dbDriver, db, WithSchema("gloat"))
+	assert.Nil(t, err)
+
+	defer db.Exec(`DROP SCHEMA IF EXISTS gloat CASCADE`)
+
+	err = dbStore.(SetupStore).Setup(context.Background())
+	assert.Nil(t, err)
+
+	err = dbStore.Insert(migration, nil)
+	assert.Nil(t, err)
+
+	var version int64
+
+	err = db.QueryRow(`SELECT version FROM gloat.schema_migrations`).Scan(&version)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 20170329154959, version)
+}
+
+func TestNullTimeScan(t *testing.T) {
+	var nt nullTime
+
+	assert.Nil(t, nt.Scan([]byte("2018-09-05 15:07:24")))
+	assert.Equal(t, time.Date(2018, 9, 5, 15, 7, 24, 0, time.UTC), nt.Time)
+
+	assert.Nil(t, nt.Scan(nil))
+	assert.True(t, nt.Time.IsZero())
+
+	assert.Error(t, nt.Scan(42))
+}
 
-		assert.Equal(t, migrations, expectedMigrations)
+func TestDatabaseStore_CollectDoesNotCreateTheTable(t *testing.T) {
+	dbStore, err := databaseStoreFactory(dbDriver, db)
+	assert.Nil(t, err)
+
+	cleanState(func() {
+		migrations, err := dbStore.Collect()
+		assert.Nil(t, err)
+		assert.Len(t, 0, migrations)
+
+		_, err = db.Exec(`SELECT version FROM schema_migrations`)
+		assert.NotNil(t, err)
 	})
 }
+
+func TestDatabaseStore_Statements(t *testing.T) {
+	migration := &Migration{
+		UpSQL:   []byte("CREATE TABLE users ();"),
+		Path:    "20170329154959_introduce_domain_model",
+		Version: 20170329154959,
+	}
+
+	dbStore := newDatabaseStore(db, SQLite3, nil)
+
+	assert.Equal(t,
+		`INSERT INTO "schema_migrations" (version, name, applied_at, duration_ms, checksum, gloat_version, dirty) VALUES (20170329154959, 'introduce_domain_model', CURRENT_TIMESTAMP, NULL, '`+checksum(migration.UpSQL)+`', '`+Version+`', FALSE);`,
+		dbStore.InsertStatement(migration),
+	)
+	assert.Equal(t, `DELETE FROM "schema_migrations" WHERE version=20170329154959;`, dbStore.RemoveStatement(migration))
+
+	statements := dbStore.CreateStatements()
+	assert.Len(t, 7, statements)
+	assert.True(t, strings.HasPrefix(statements[1], "-- Tables created by older gloat versions"))
+	assert.True(t, strings.HasSuffix(statements[6], "-- ALTER TABLE \"schema_migrations\" ADD COLUMN dirty BOOLEAN"))
+
+	postgresStore := newDatabaseStore(db, PostgreSQL, nil)
+	assert.Equal(t, `ALTER TABLE "schema_migrations" ADD COLUMN IF NOT EXISTS dirty BOOLEAN`, postgresStore.CreateStatements()[6])
+
+	mysqlStore := newDatabaseStore(db, MySQL, []StoreOption{WithTable("gloat's")})
+	assert.Equal(t, "DELETE FROM `gloat's` WHERE version=20170329154959;", mysqlStore.RemoveStatement(migration))
+	assert.Equal(t, `'it''s \\ fine'`, MySQL.quoteString(`it's \ fine`))
+}
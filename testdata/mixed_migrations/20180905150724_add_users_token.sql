@@ -0,0 +1,8 @@
+-- Tokens are used for the password resets.
+-- gloat:options {"transaction": false}
+
+-- gloat:up
+ALTER TABLE users ADD COLUMN token character varying;
+
+-- gloat:down
+ALTER TABLE users DROP COLUMN token;
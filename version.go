@@ -0,0 +1,110 @@
+package gloat
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// VersionGenerator generates the versions of new migrations.
+type VersionGenerator interface {
+	NextVersion() (int64, error)
+}
+
+// VersionFormatter is a VersionGenerator that formats the versions in the
+// paths of the migrations on its own, like zero-padded sequential numbers.
+// The versions of other generators are written as plain numbers.
+type VersionFormatter interface {
+	FormatVersion(int64) string
+}
+
+// TimestampVersionGenerator generates versions out of the current UTC time,
+// like 20170329154959. The versions always grow, if two are generated within
+// the same second, the second one is bumped a second ahead.
+type TimestampVersionGenerator struct {
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// NextVersion implements the VersionGenerator interface.
+func (g *TimestampVersionGenerator) NextVersion() (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now
+	if g.Now != nil {
+		now = g.Now
+	}
+
+	current := now().UTC().Truncate(time.Second)
+	if !current.After(g.last) {
+		current = g.last.Add(time.Second)
+	}
+	g.last = current
+
+	return strconv.ParseInt(current.Format(versionFormat), 10, 64)
+}
+
+// NewTimestampVersionGenerator creates a VersionGenerator of timestamps.
+func NewTimestampVersionGenerator() VersionGenerator {
+	return &TimestampVersionGenerator{}
+}
+
+// NewFixedClockVersionGenerator creates a VersionGenerator of timestamps
+// starting at a fixed time, so the generated versions are predictable, e.g.
+// in tests. The versions following the first one are a second apart.
+func NewFixedClockVersionGenerator(t time.Time) VersionGenerator {
+	return &TimestampVersionGenerator{Now: func() time.Time { return t }}
+}
+
+// SequentialVersionGenerator generates versions that follow the highest one
+// in a Source, like 0001, 0002 and so on.
+type SequentialVersionGenerator struct {
+	Source Source
+
+	// Width is the number of digits the versions are zero-padded to in the
+	// migration paths.
+	Width int
+
+	mu   sync.Mutex
+	last int64
+}
+
+// NextVersion implements the VersionGenerator interface.
+func (g *SequentialVersionGenerator) NextVersion() (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	migrations, err := g.Source.Collect()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, migration := range migrations {
+		if migration.Version > g.last {
+			g.last = migration.Version
+		}
+	}
+
+	g.last++
+
+	return g.last, nil
+}
+
+// FormatVersion implements the VersionFormatter interface.
+func (g *SequentialVersionGenerator) FormatVersion(version int64) string {
+	return fmt.Sprintf("%0*d", g.Width, version)
+}
+
+// NewSequentialVersionGenerator creates a VersionGenerator of sequential
+// numbers, zero-padded to 4 digits.
+func NewSequentialVersionGenerator(source Source) VersionGenerator {
+	return &SequentialVersionGenerator{Source: source, Width: 4}
+}
+
+// defaultVersionGenerator backs GenerateMigration.
+var defaultVersionGenerator = NewTimestampVersionGenerator()
@@ -0,0 +1,48 @@
+package gloat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func TestTimestampVersionGenerator(t *testing.T) {
+	generator := NewFixedClockVersionGenerator(time.Date(2017, 3, 29, 15, 49, 59, 0, time.UTC))
+
+	first, err := generator.NextVersion()
+	assert.Nil(t, err)
+	assert.Equal(t, 20170329154959, first)
+
+	second, err := generator.NextVersion()
+	assert.Nil(t, err)
+	assert.Equal(t, 20170329155000, second)
+}
+
+func TestSequentialVersionGenerator(t *testing.T) {
+	noop := func(context.Context, SQLExecer) error { return nil }
+
+	source := NewGoSource()
+	source.Register(1, "introduce_domain_model", noop, nil)
+	source.Register(7, "add_users", noop, nil)
+
+	generator := NewSequentialVersionGenerator(source)
+
+	migration, err := GenerateMigrationWithVersion(generator, "addPosts")
+	assert.Nil(t, err)
+	assert.Equal(t, 8, migration.Version)
+	assert.Equal(t, "0008_add_posts", migration.Path)
+
+	migration, err = GenerateMigrationWithVersion(generator, "add_comments")
+	assert.Nil(t, err)
+	assert.Equal(t, 9, migration.Version)
+	assert.Equal(t, "0009_add_comments", migration.Path)
+}
+
+func TestGenerateMigration_UniqueVersions(t *testing.T) {
+	first := GenerateMigration("add_users")
+	second := GenerateMigration("add_posts")
+
+	assert.True(t, second.Version > first.Version)
+}